@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -45,7 +46,12 @@ func main() {
 		return nil
 	})
 
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	command, err := app.Parse(os.Args[1:])
+	if errors.As(err, &commands.ChangesDetectedError{}) {
+		pushGateway.Stop()
+		os.Exit(commands.ChangesDetectedExitCode)
+	}
+	kingpin.MustParse(command, err)
 
 	pushGateway.Stop()
 }
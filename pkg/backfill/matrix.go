@@ -0,0 +1,63 @@
+package backfill
+
+import (
+	"io"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// matrixIterator iterates over the samples of a range query result, series
+// by series.
+type matrixIterator struct {
+	matrix    model.Matrix
+	posSeries int
+	posSample int
+
+	// labels are reused across samples within a series
+	labels          labels.Labels
+	labelsSeriesPos int
+}
+
+// NewMatrixIterator returns an Iterator over the samples of a matrix.
+func NewMatrixIterator(m model.Matrix) Iterator {
+	return &matrixIterator{
+		matrix:          m,
+		posSample:       -1,
+		labelsSeriesPos: -1,
+	}
+}
+
+func (i *matrixIterator) Next() error {
+	for i.posSeries < len(i.matrix) {
+		i.posSample++
+		if i.posSample < len(i.matrix[i.posSeries].Values) {
+			return nil
+		}
+		i.posSample = -1
+		i.posSeries++
+	}
+	return io.EOF
+}
+
+func (i *matrixIterator) Labels() labels.Labels {
+	if i.posSeries == i.labelsSeriesPos {
+		return i.labels
+	}
+
+	metric := i.matrix[i.posSeries].Metric
+	b := labels.NewScratchBuilder(len(metric))
+	for name, value := range metric {
+		b.Add(string(name), string(value))
+	}
+	b.Sort()
+
+	i.labels = b.Labels()
+	i.labelsSeriesPos = i.posSeries
+	return i.labels
+}
+
+func (i *matrixIterator) Sample() (int64, float64) {
+	s := i.matrix[i.posSeries].Values[i.posSample]
+	return int64(s.Timestamp), float64(s.Value)
+}
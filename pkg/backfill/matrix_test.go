@@ -0,0 +1,40 @@
+package backfill
+
+import (
+	"io"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixIterator(t *testing.T) {
+	it := NewMatrixIterator(model.Matrix{
+		{Metric: model.Metric{"__name__": "a", "job": "x"}, Values: []model.SamplePair{{Timestamp: 1, Value: 1}, {Timestamp: 2, Value: 2}}},
+		{Metric: model.Metric{"__name__": "b"}},
+		{Metric: model.Metric{"__name__": "c"}, Values: []model.SamplePair{{Timestamp: 3, Value: 3}}},
+	})
+
+	type sample struct {
+		labels string
+		ts     int64
+		v      float64
+	}
+	var got []sample
+	for {
+		err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		ts, v := it.Sample()
+		got = append(got, sample{it.Labels().String(), ts, v})
+	}
+
+	require.Equal(t, []sample{
+		{labels.FromStrings("__name__", "a", "job", "x").String(), 1, 1},
+		{labels.FromStrings("__name__", "a", "job", "x").String(), 2, 2},
+		{labels.FromStrings("__name__", "c").String(), 3, 3},
+	}, got)
+}
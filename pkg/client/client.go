@@ -224,5 +224,13 @@ func buildRequest(ctx context.Context, p, m string, endpoint url.URL, payload []
 		endpoint.RawPath = joinPath(endpoint.EscapedPath(), pURL.EscapedPath())
 	}
 	endpoint.Path = joinPath(endpoint.Path, pURL.Path)
+	// keep the query parameters of the address, such as ones required by a
+	// proxy, along with the ones of the path
+	switch {
+	case endpoint.RawQuery == "":
+		endpoint.RawQuery = pURL.RawQuery
+	case pURL.RawQuery != "":
+		endpoint.RawQuery += "&" + pURL.RawQuery
+	}
 	return http.NewRequestWithContext(ctx, m, endpoint.String(), bytes.NewBuffer(payload))
 }
@@ -82,6 +82,27 @@ func TestBuildURL(t *testing.T) {
 			url:       "http://cortexurl.com/apathto",
 			resultURL: "http://cortexurl.com/apathto/api/v1/rules/last-char-slash%2F",
 		},
+		{
+			name:      "builds the correct URL when the target path has a query string",
+			path:      "/api/prom/api/v1/query_range?query=up%7Bjob%3D%22a%22%7D&step=60",
+			method:    http.MethodGet,
+			url:       "http://cortexurl.com/apathto",
+			resultURL: "http://cortexurl.com/apathto/api/prom/api/v1/query_range?query=up%7Bjob%3D%22a%22%7D&step=60",
+		},
+		{
+			name:      "builds the correct URL when the base url has a query string",
+			path:      "/api/v1/rules",
+			method:    http.MethodGet,
+			url:       "http://cortexurl.com/apathto?token=abc",
+			resultURL: "http://cortexurl.com/apathto/api/v1/rules?token=abc",
+		},
+		{
+			name:      "builds the correct URL when both the base url and the target path have a query string",
+			path:      "/api/prom/api/v1/query_range?query=up&step=60",
+			method:    http.MethodGet,
+			url:       "http://cortexurl.com/apathto?token=abc",
+			resultURL: "http://cortexurl.com/apathto/api/prom/api/v1/query_range?token=abc&query=up&step=60",
+		},
 	}
 
 	for _, tt := range tc {
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const metadataAPIPath = "/api/prom/api/v1/metadata"
+
+// metricMetadata is the metadata of a metric returned by the metadata API.
+type metricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// metadataResponse is the body of a Prometheus metadata API response.
+type metadataResponse struct {
+	Status    string                      `json:"status"`
+	ErrorType string                      `json:"errorType"`
+	Error     string                      `json:"error"`
+	Data      map[string][]metricMetadata `json:"data"`
+}
+
+// MetricTypes returns the type of every metric known to the Cortex cluster,
+// indexed by metric name.
+func (r *CortexClient) MetricTypes(ctx context.Context) (map[string]string, error) {
+	res, err := r.doRequest(ctx, metadataAPIPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body metadataResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "unable to decode metadata response")
+	}
+
+	if body.Status != "success" {
+		return nil, fmt.Errorf("metadata query failed: %s: %s", body.ErrorType, body.Error)
+	}
+
+	types := make(map[string]string, len(body.Data))
+	for name, metadata := range body.Data {
+		if len(metadata) > 0 {
+			types[name] = metadata[0].Type
+		}
+	}
+	return types, nil
+}
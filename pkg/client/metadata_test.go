@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricTypes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/prom/api/v1/metadata", r.URL.Path)
+		fmt.Fprintln(w, `{"status":"success","data":{"up":[{"type":"gauge","help":"","unit":""}],"http_requests_total":[{"type":"counter","help":"","unit":""}]}}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-tenant-id"})
+	require.NoError(t, err)
+
+	types, err := client.MetricTypes(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"up": "gauge", "http_requests_total": "counter"}, types)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	queryAPIPath      = "/api/prom/api/v1/query"
+	queryRangeAPIPath = "/api/prom/api/v1/query_range"
+)
+
+// queryResponse is the body of a Prometheus instant query API response.
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		// Result is decoded once its type is known.
+		Result json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// queryRangeResponse is the body of a Prometheus range query API response.
+type queryRangeResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string       `json:"resultType"`
+		Result     model.Matrix `json:"result"`
+	} `json:"data"`
+}
+
+// QueryInstant executes a PromQL instant query returning a vector against the
+// Cortex cluster.
+func (r *CortexClient) QueryInstant(ctx context.Context, query string, ts time.Time) (model.Vector, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatTime(ts))
+
+	res, err := r.doRequest(ctx, queryAPIPath+"?"+params.Encode(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body queryResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "unable to decode query response")
+	}
+
+	if body.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s: %s", body.ErrorType, body.Error)
+	}
+	if body.Data.ResultType != model.ValVector.String() {
+		return nil, fmt.Errorf("unexpected query result type %q", body.Data.ResultType)
+	}
+
+	var vector model.Vector
+	if err := json.Unmarshal(body.Data.Result, &vector); err != nil {
+		return nil, errors.Wrap(err, "unable to decode query result")
+	}
+	return vector, nil
+}
+
+// QueryRange executes a PromQL range query against the Cortex cluster.
+func (r *CortexClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	res, err := r.doRequest(ctx, queryRangeAPIPath+"?"+params.Encode(), "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body queryRangeResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "unable to decode range query response")
+	}
+
+	if body.Status != "success" {
+		return nil, fmt.Errorf("range query failed: %s: %s", body.ErrorType, body.Error)
+	}
+	if body.Data.ResultType != model.ValMatrix.String() {
+		return nil, fmt.Errorf("unexpected range query result type %q", body.Data.ResultType)
+	}
+
+	return body.Data.Result, nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', -1, 64)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRange(t *testing.T) {
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		if r.URL.Query().Get("query") == "invalid(" {
+			fmt.Fprintln(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"a"},"values":[[60,"1"],[120,"1"]]}]}}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-tenant-id"})
+	require.NoError(t, err)
+
+	start, end := time.Unix(60, 0), time.Unix(120, 0)
+	matrix, err := client.QueryRange(context.Background(), `up{job="a"} == 1`, start, end, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, model.Matrix{{
+		Metric: model.Metric{"job": "a"},
+		Values: []model.SamplePair{{Timestamp: 60000, Value: 1}, {Timestamp: 120000, Value: 1}},
+	}}, matrix)
+
+	require.Equal(t, "/api/prom/api/v1/query_range", req.URL.Path)
+	require.Equal(t, `up{job="a"} == 1`, req.URL.Query().Get("query"))
+	require.Equal(t, "60", req.URL.Query().Get("start"))
+	require.Equal(t, "120", req.URL.Query().Get("end"))
+	require.Equal(t, "60", req.URL.Query().Get("step"))
+	require.Equal(t, "my-tenant-id", req.Header.Get("X-Scope-OrgID"))
+
+	_, err = client.QueryRange(context.Background(), "invalid(", start, end, time.Minute)
+	require.EqualError(t, err, "range query failed: bad_data: parse error")
+}
+
+func TestQueryInstant(t *testing.T) {
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		switch r.URL.Query().Get("query") {
+		case "invalid(":
+			fmt.Fprintln(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		case "1":
+			fmt.Fprintln(w, `{"status":"success","data":{"resultType":"scalar","result":[60,"1"]}}`)
+		default:
+			fmt.Fprintln(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a"},"value":[60,"2"]}]}}`)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-tenant-id"})
+	require.NoError(t, err)
+
+	vector, err := client.QueryInstant(context.Background(), `count(up{job="a"})`, time.Unix(60, 0))
+	require.NoError(t, err)
+	require.Equal(t, model.Vector{{Metric: model.Metric{"job": "a"}, Value: 2, Timestamp: 60000}}, vector)
+
+	require.Equal(t, "/api/prom/api/v1/query", req.URL.Path)
+	require.Equal(t, `count(up{job="a"})`, req.URL.Query().Get("query"))
+	require.Equal(t, "60", req.URL.Query().Get("time"))
+	require.Equal(t, "my-tenant-id", req.Header.Get("X-Scope-OrgID"))
+
+	_, err = client.QueryInstant(context.Background(), "invalid(", time.Unix(60, 0))
+	require.EqualError(t, err, "query failed: bad_data: parse error")
+
+	_, err = client.QueryInstant(context.Background(), "1", time.Unix(60, 0))
+	require.EqualError(t, err, `unexpected query result type "scalar"`)
+}
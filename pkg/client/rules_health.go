@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/cortex-tools/pkg/rules"
+)
+
+const rulesHealthAPIPath = "/api/prom/api/v1/rules"
+
+// ruleStatus is a rule returned by the Prometheus rules API.
+type ruleStatus struct {
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Health         string    `json:"health"`
+	State          string    `json:"state"`
+	LastError      string    `json:"lastError"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+	EvaluationTime float64   `json:"evaluationTime"`
+	Alerts         []struct {
+		State string `json:"state"`
+	} `json:"alerts"`
+}
+
+// ruleGroupStatus is a rule group returned by the Prometheus rules API. The
+// file of a group is its namespace.
+type ruleGroupStatus struct {
+	Name           string       `json:"name"`
+	File           string       `json:"file"`
+	LastEvaluation time.Time    `json:"lastEvaluation"`
+	EvaluationTime float64      `json:"evaluationTime"`
+	Rules          []ruleStatus `json:"rules"`
+}
+
+// rulesResponse is the body of a Prometheus rules API response.
+type rulesResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		Groups []ruleGroupStatus `json:"groups"`
+	} `json:"data"`
+}
+
+// RulesHealth returns the evaluation state of every rule group of the tenant,
+// from the Prometheus-compatible rules API. Groups are sorted by namespace and
+// keep the order of the ruler within a namespace.
+func (r *CortexClient) RulesHealth(ctx context.Context) ([]rules.GroupHealth, error) {
+	res, err := r.doRequest(ctx, rulesHealthAPIPath, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body rulesResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrap(err, "unable to decode rules response")
+	}
+
+	if body.Status != "success" {
+		return nil, fmt.Errorf("rules query failed: %s: %s", body.ErrorType, body.Error)
+	}
+
+	groups := make([]rules.GroupHealth, 0, len(body.Data.Groups))
+	for _, g := range body.Data.Groups {
+		ruleHealth := make([]rules.RuleHealth, 0, len(g.Rules))
+		for _, rule := range g.Rules {
+			h := rules.RuleHealth{
+				Rule:              rule.Name,
+				Type:              rule.Type,
+				Health:            rule.Health,
+				State:             rule.State,
+				LastError:         rule.LastError,
+				LastEvaluation:    rule.LastEvaluation,
+				EvaluationSeconds: rule.EvaluationTime,
+			}
+			for _, a := range rule.Alerts {
+				if a.State == rules.AlertStateFiring {
+					h.FiringAlerts++
+				}
+			}
+			ruleHealth = append(ruleHealth, h)
+		}
+
+		groups = append(groups, rules.NewGroupHealth(g.File, g.Name, g.LastEvaluation, g.EvaluationTime, ruleHealth))
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Namespace < groups[j].Namespace })
+	return groups, nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/cortex-tools/pkg/rules"
+)
+
+func TestRulesHealth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/prom/api/v1/rules", r.URL.Path)
+		fmt.Fprintln(w, `{"status":"success","data":{"groups":[
+			{"name":"alerts","file":"team-b","lastEvaluation":"2023-01-02T03:04:05Z","evaluationTime":0.002,"rules":[
+				{"name":"HighErrorRate","type":"alerting","health":"ok","state":"firing","lastEvaluation":"2023-01-02T03:04:05Z","evaluationTime":0.0015,
+				 "alerts":[{"state":"firing"},{"state":"pending"},{"state":"firing"}]}
+			]},
+			{"name":"recordings","file":"team-a","lastEvaluation":"2023-01-02T03:04:00Z","evaluationTime":0.01,"rules":[
+				{"name":"job:up:sum","type":"recording","health":"err","lastError":"query timed out","lastEvaluation":"2023-01-02T03:04:00Z","evaluationTime":0.01}
+			]}
+		]}}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-tenant-id"})
+	require.NoError(t, err)
+
+	groups, err := client.RulesHealth(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []rules.GroupHealth{
+		{
+			Namespace:         "team-a",
+			Group:             "recordings",
+			Health:            rules.HealthError,
+			LastError:         "query timed out",
+			LastEvaluation:    time.Date(2023, 1, 2, 3, 4, 0, 0, time.UTC),
+			EvaluationSeconds: 0.01,
+			Rules: []rules.RuleHealth{
+				{Rule: "job:up:sum", Type: "recording", Health: rules.HealthError, LastError: "query timed out", LastEvaluation: time.Date(2023, 1, 2, 3, 4, 0, 0, time.UTC), EvaluationSeconds: 0.01},
+			},
+		},
+		{
+			Namespace:         "team-b",
+			Group:             "alerts",
+			Health:            rules.HealthOK,
+			LastEvaluation:    time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			EvaluationSeconds: 0.002,
+			FiringAlerts:      2,
+			Rules: []rules.RuleHealth{
+				{Rule: "HighErrorRate", Type: "alerting", Health: rules.HealthOK, State: "firing", LastEvaluation: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC), EvaluationSeconds: 0.0015, FiringAlerts: 2},
+			},
+		},
+	}, groups)
+}
+
+func TestRulesHealth_Error(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"status":"error","errorType":"internal","error":"ruler unavailable"}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-tenant-id"})
+	require.NoError(t, err)
+
+	_, err = client.RulesHealth(context.Background())
+	require.EqualError(t, err, "rules query failed: internal: ruler unavailable")
+}
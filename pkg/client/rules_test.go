@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -74,3 +75,29 @@ func TestCortexClient_X(t *testing.T) {
 	}
 
 }
+
+func TestCortexClient_FederatedRuleGroup(t *testing.T) {
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		fmt.Fprint(w, "federated:\n  - name: cross_tenant\n    limit: 10\n    source_tenants: [tenant-a, tenant-b]\n    rules:\n      - record: job:up:sum\n        expr: sum by (job) (up)\n")
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	groups, err := client.ListRules(context.Background(), "")
+	require.NoError(t, err)
+	group := groups["federated"][0]
+	require.Equal(t, 10, group.Limit)
+	require.Equal(t, []string{"tenant-a", "tenant-b"}, group.SourceTenants)
+
+	require.NoError(t, client.CreateRuleGroup(context.Background(), "federated", group))
+	require.Contains(t, string(body), "limit: 10\n")
+	require.Contains(t, string(body), "source_tenants:\n    - tenant-a\n    - tenant-b\n")
+}
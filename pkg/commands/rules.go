@@ -6,23 +6,35 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
-	yamlv3 "gopkg.in/yaml.v3"
 
+	"github.com/grafana/cortex-tools/pkg/backfill"
 	"github.com/grafana/cortex-tools/pkg/client"
 	"github.com/grafana/cortex-tools/pkg/printer"
 	"github.com/grafana/cortex-tools/pkg/rules"
 	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+	"github.com/grafana/cortex-tools/pkg/rules/unittest"
 )
 
 const (
 	defaultPrepareAggregationLabel = "cluster"
+
+	textOutputFormat = "text"
+	jsonOutputFormat = "json"
+	yamlOutputFormat = "yaml"
+
+	// ChangesDetectedExitCode is the exit code of the diff and sync commands when
+	// --exit-code is set and the rule set has changes.
+	ChangesDetectedExitCode = 2
 )
 
 var (
@@ -37,10 +49,20 @@ var (
 		Help:      "The timestamp of the last successful rule load.",
 	})
 
-	backends = []string{rules.CortexBackend, rules.LokiBackend} // list of supported backend types
-	formats  = []string{"json", "yaml", "table"}                // list of supported formats for the list command
+	backends      = []string{rules.CortexBackend, rules.LokiBackend}               // list of supported backend types
+	ruleFormats   = []string{rules.CortextoolFormat, rules.PrometheusRuleFormat}   // list of supported rule file formats
+	formats       = []string{"json", "yaml", "table"}                              // list of supported formats for the list command
+	outputFormats = []string{textOutputFormat, jsonOutputFormat, yamlOutputFormat} // list of supported output formats for the diff and sync commands
 )
 
+// ChangesDetectedError is returned by the diff and sync commands when --exit-code
+// is set and the rule set has changes. The CLI exits with ChangesDetectedExitCode.
+type ChangesDetectedError struct{}
+
+func (ChangesDetectedError) Error() string {
+	return "changes detected"
+}
+
 // RuleCommand configures and executes rule related cortex operations
 type RuleCommand struct {
 	ClientConfig client.Config
@@ -55,15 +77,23 @@ type RuleCommand struct {
 	RuleGroup string
 
 	// Load Rules Config
-	RuleFilesList []string
-	RuleFiles     string
-	RuleFilesPath string
+	RuleFilesList     []string
+	RuleFiles         string
+	RuleFilesPath     string
+	RuleFormat        string
+	NamespaceTemplate string
+	ValuesFile        string
+	OverlayFiles      []string
+	templateValues    map[string]interface{}
+	overlays          []rules.Overlay
 
 	// Sync/Diff Rules Config
 	Namespaces           string
 	namespacesMap        map[string]struct{}
 	IgnoredNamespaces    string
 	ignoredNamespacesMap map[string]struct{}
+	// staticRuleFiles are the rule files not found in the rule directories.
+	staticRuleFiles []string
 
 	// Prepare Rules Config
 	InPlaceEdit                            bool
@@ -75,15 +105,83 @@ type RuleCommand struct {
 	LintDryRun bool
 
 	// Rules check flags
-	Strict bool
+	Strict                  bool
+	PolicyFile              string
+	CheckEvaluationInterval time.Duration
+	CheckFix                bool
+
+	// Test Rules Config
+	TestFilesList []string
+
+	// Backtest Rules Config
+	BacktestWindow             time.Duration
+	BacktestEnd                string
+	BacktestEvaluationInterval time.Duration
+	BacktestFlapWindow         time.Duration
+
+	// Backfill Rules Config
+	BackfillStart              string
+	BackfillEnd                string
+	BackfillEvaluationInterval time.Duration
+	BackfillTSDBPath           string
+
+	// Cost Rules Config
+	CostEvaluationInterval time.Duration
+	CostMaxUtilization     float64
+
+	// Rewrite Rules Config
+	RewriteRenameMetrics []string
+	RewriteAddMatchers   []string
+	RewriteDropLabels    []string
+	RewriteRenameLabels  []string
+	RewriteDryRun        bool
 
 	// List Rules Config
 	Format string
 
 	DisableColor bool
 
+	// List/Status Rules Config
+	ListHealth bool
+	Unhealthy  bool
+
 	// Diff Rules Config
-	Verbose bool
+	Verbose              bool
+	StrictExprComparison bool
+
+	// Diff/Sync output Config
+	OutputFormat string
+	ExitCode     bool
+
+	// Sync execution Config
+	SyncConcurrency int
+	ContinueOnError bool
+
+	// Sync safety Config
+	DryRun              bool
+	MaxDeletions        int
+	MaxDeletionsPercent float64
+	AllowEmpty          bool
+	AutoApprove         bool
+
+	// Backup/Restore Rules Config
+	BackupDir    string
+	RestorePrune bool
+
+	// Multi-tenant sync Config
+	TenantsDir              string
+	TenantNamespaces        map[string]string
+	TenantIgnoredNamespaces map[string]string
+	TenantConcurrency       int
+
+	// Copy Rules Config
+	CopySource  client.Config
+	CopyRenames map[string]string
+
+	// Sync watch mode Config
+	Watch              bool
+	WatchInterval      time.Duration
+	WatchListenAddress string
 }
 
 // Register rule related commands and flags with the kingpin application
@@ -93,6 +191,10 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 	rulesCmd.Flag("user", "API user to use when contacting cortex, alternatively set CORTEX_API_USER. If empty, CORTEX_TENANT_ID will be used instead.").Default("").Envar("CORTEX_API_USER").StringVar(&r.ClientConfig.User)
 	rulesCmd.Flag("key", "API key to use when contacting cortex, alternatively set CORTEX_API_KEY.").Default("").Envar("CORTEX_API_KEY").StringVar(&r.ClientConfig.Key)
 	rulesCmd.Flag("backend", "Backend type to interact with: <cortex|loki>").Default("cortex").EnumVar(&r.Backend, backends...)
+	rulesCmd.Flag("rule-format", "Format of the rule files: <cortextool|prometheusrule>. The prometheusrule format reads and writes Kubernetes PrometheusRule resources.").Default(rules.CortextoolFormat).EnumVar(&r.RuleFormat, ruleFormats...)
+	rulesCmd.Flag("namespace-template", "Go template naming the namespace of a PrometheusRule resource from its metadata .Namespace and .Name.").Default(rules.DefaultNamespaceTemplate).StringVar(&r.NamespaceTemplate)
+	rulesCmd.Flag("values", "YAML file of values to render the rule files with, as Go templates using the [[ and ]] delimiters. Values are available as .Values.").ExistingFileVar(&r.ValuesFile)
+	rulesCmd.Flag("overlay", "Overlay file patching rule groups and rules of the rule files by name. Flag can be repeated, overlays are applied in order.").ExistingFilesVar(&r.OverlayFiles)
 	r.ClientConfig.ExtraHeaders = map[string]string{}
 	rulesCmd.Flag("extra-headers", "Extra headers to add to the requests in header=value format, alternatively set newline separated CORTEX_EXTRA_HEADERS.").Envar("CORTEX_EXTRA_HEADERS").StringMapVar(&r.ClientConfig.ExtraHeaders)
 
@@ -117,28 +219,65 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		Action(r.diffRules)
 	syncRulesCmd := rulesCmd.
 		Command("sync", "sync a set of rules to a designated cortex endpoint").
+		Validate(r.validateTenantConcurrency).
 		Action(r.syncRules)
 	prepareCmd := rulesCmd.
 		Command("prepare", "modifies a set of rules by including an specific label in aggregations.").
 		Action(r.prepare)
 	lintCmd := rulesCmd.
-		Command("lint", "formats a set of rule files. It reorders keys alphabetically, uses 4 spaces as indentantion, and formats PromQL expressions to a single line.").
+		Command("lint", "formats a set of rule files. It formats PromQL expressions to a single line, keeping the comments, key order and indentation of the files.").
 		Action(r.lint)
 	checkCmd := rulesCmd.
 		Command("check", "runs various best practice checks against rules.").
 		Action(r.checkRecordingRuleNames)
+	testCmd := rulesCmd.
+		Command("test", "runs unit tests against a set of rule files, using the promtool unit test file format.").
+		Action(r.testRules)
+	backtestCmd := rulesCmd.
+		Command("backtest", "evaluates the alerting rules of a set of rule files against the historical data of a designated cortex endpoint.").
+		Action(r.backtestRules)
+	backfillCmd := rulesCmd.
+		Command("backfill", "evaluates the recording rules of a set of rule files over a past time range and writes the results into TSDB blocks.").
+		Action(r.backfillRules)
+	costCmd := rulesCmd.
+		Command("cost", "estimates the cardinality and query latency of the rules of a set of rule files, or of a designated cortex endpoint, and flags the rule groups likely to miss evaluations.").
+		Action(r.costRules)
+	graphCmd := rulesCmd.
+		Command("graph", "exports the dependency graph of the rules of a set of rule files and warns about evaluation order hazards and cycles.").
+		Action(r.graphRules)
+	rewriteCmd := rulesCmd.
+		Command("rewrite", "rewrites the PromQL expressions of a set of rule files, renaming metrics and labels, adding matchers and dropping labels.").
+		Action(r.rewriteRules)
+	backupCmd := rulesCmd.
+		Command("backup", "writes the rules of the tenant to a directory, one rule file per namespace, along with a manifest describing the backup.").
+		Action(r.backupRules)
+	restoreCmd := rulesCmd.
+		Command("restore", "re-applies the rules of a backup directory written by the backup command to the tenant.").
+		Validate(r.validateConcurrency).
+		Action(r.restoreRules)
+	copyCmd := rulesCmd.
+		Command("copy", "copies the rules of a source tenant, possibly of another cluster, to the tenant, applying only the differences.").
+		Validate(r.validateConcurrency).
+		Action(r.copyRules)
+	statusCmd := rulesCmd.
+		Command("status", "Show the evaluation health, last error and firing alerts of every rule currently in the cortex ruler.").
+		Action(r.rulesStatus)
 
 	// Require Cortex cluster address and tentant ID on all these commands
-	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd} {
+	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd, backtestCmd, backfillCmd, costCmd, backupCmd, restoreCmd, statusCmd, copyCmd} {
 		c.Flag("address", "Address of the cortex cluster, alternatively set CORTEX_ADDRESS.").
 			Envar("CORTEX_ADDRESS").
 			Required().
 			StringVar(&r.ClientConfig.Address)
 
-		c.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID.").
-			Envar("CORTEX_TENANT_ID").
-			Required().
-			StringVar(&r.ClientConfig.ID)
+		id := c.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID.").
+			Envar("CORTEX_TENANT_ID")
+		// The sync command can sync several tenants at once with --tenants-dir,
+		// it checks the id itself.
+		if c != syncRulesCmd {
+			id = id.Required()
+		}
+		id.StringVar(&r.ClientConfig.ID)
 
 		c.Flag("use-legacy-routes", "If set, API requests to cortex will use the legacy /api/prom/ routes, alternatively set CORTEX_USE_LEGACY_ROUTES.").
 			Default("false").
@@ -176,9 +315,11 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 
 	// Load Rules Command
 	loadRulesCmd.Arg("rule-files", "The rule files to check.").Required().ExistingFilesVar(&r.RuleFilesList)
+	loadRulesCmd.Flag("strict-expr-comparison", "Compare rule expressions as text, instead of comparing their parsed form which ignores formatting differences.").BoolVar(&r.StrictExprComparison)
 
 	// Diff Command
 	diffRulesCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
+	diffRulesCmd.Flag("strict-expr-comparison", "Compare rule expressions as text, instead of comparing their parsed form which ignores formatting differences.").BoolVar(&r.StrictExprComparison)
 	diffRulesCmd.Flag("namespaces", "comma-separated list of namespaces to check during a diff. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
 	diffRulesCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a diff. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
 	diffRulesCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
@@ -187,10 +328,13 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
 	diffRulesCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
-	diffRulesCmd.Flag("verbose", "show diff output with rules changes").BoolVar(&r.Verbose)
+	diffRulesCmd.Flag("verbose", "show a unified diff of every changed rule and group field").BoolVar(&r.Verbose)
+	diffRulesCmd.Flag("output-format", "Format of the diff output: <text|json|yaml>").Default(textOutputFormat).EnumVar(&r.OutputFormat, outputFormats...)
+	diffRulesCmd.Flag("exit-code", fmt.Sprintf("exit with status %d if any changes are detected", ChangesDetectedExitCode)).BoolVar(&r.ExitCode)
 
 	// Sync Command
 	syncRulesCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
+	syncRulesCmd.Flag("strict-expr-comparison", "Compare rule expressions as text, instead of comparing their parsed form which ignores formatting differences.").BoolVar(&r.StrictExprComparison)
 	syncRulesCmd.Flag("namespaces", "comma-separated list of namespaces to check during a diff. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
 	syncRulesCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a sync. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
 	syncRulesCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
@@ -198,6 +342,45 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
+	syncRulesCmd.Flag("output-format", "Format of the sync summary: <text|json|yaml>").Default(textOutputFormat).EnumVar(&r.OutputFormat, outputFormats...)
+	syncRulesCmd.Flag("dry-run", "Print the changes that would be applied without applying them.").Short('n').BoolVar(&r.DryRun)
+	syncRulesCmd.Flag("verbose", "show a unified diff of every changed rule and group field when printing the changes").BoolVar(&r.Verbose)
+	syncRulesCmd.Flag("max-deletions", "Abort the sync if it would delete more than this number of rule groups. 0 means no limit.").Default("0").IntVar(&r.MaxDeletions)
+	syncRulesCmd.Flag("max-deletions-percent", "Abort the sync if it would delete more than this percentage of the rule groups currently stored. 0 means no limit.").Default("0").Float64Var(&r.MaxDeletionsPercent)
+	syncRulesCmd.Flag("allow-empty", "Allow syncing an empty local rule set, which deletes every rule group of the checked namespaces.").BoolVar(&r.AllowEmpty)
+	syncRulesCmd.Flag("yes", "Apply the changes without asking for confirmation. Confirmation is only asked for when stdin is a terminal.").Short('y').BoolVar(&r.AutoApprove)
+	syncRulesCmd.Flag("concurrency", "Maximum number of rule group changes applied concurrently.").Default("1").IntVar(&r.SyncConcurrency)
+	syncRulesCmd.Flag("continue-on-error", "Keep applying the remaining rule group changes when one of them fails, instead of stopping at the first failure.").BoolVar(&r.ContinueOnError)
+	syncRulesCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+	syncRulesCmd.Flag("tenants-dir", "Directory holding the rule files of several tenants, in one subdirectory named after the id of each tenant. Every tenant is synced and --id is ignored.").ExistingDirVar(&r.TenantsDir)
+	r.TenantNamespaces = map[string]string{}
+	r.TenantIgnoredNamespaces = map[string]string{}
+	syncRulesCmd.Flag("tenant-namespaces", "Namespaces to sync for a tenant of --tenants-dir, in tenant=namespace,... format. Flag can be repeated, --namespaces applies to the other tenants.").StringMapVar(&r.TenantNamespaces)
+	syncRulesCmd.Flag("tenant-ignored-namespaces", "Namespaces to ignore for a tenant of --tenants-dir, in tenant=namespace,... format. Flag can be repeated, --ignored-namespaces applies to the other tenants.").StringMapVar(&r.TenantIgnoredNamespaces)
+	syncRulesCmd.Flag("tenant-concurrency", "Maximum number of tenants of --tenants-dir synced concurrently.").Default("1").IntVar(&r.TenantConcurrency)
+	syncRulesCmd.Flag("watch", "Keep running, syncing the rule files every time they change and on every --watch-interval to correct drift. Changes are applied without confirmation.").BoolVar(&r.Watch)
+	syncRulesCmd.Flag("watch-interval", "Interval of the periodic syncs of --watch.").Default("5m").DurationVar(&r.WatchInterval)
+	syncRulesCmd.Flag("watch-listen-address", "Address to serve the /metrics and /ready endpoints on with --watch, required with --watch.").StringVar(&r.WatchListenAddress)
+	syncRulesCmd.Flag("exit-code", fmt.Sprintf("exit with status %d if any changes were applied", ChangesDetectedExitCode)).BoolVar(&r.ExitCode)
+
+	// Backup Command
+	backupCmd.Flag("dir", "Directory to write the backup to, it is created if it doesn't exist.").Required().StringVar(&r.BackupDir)
+
+	// Restore Command
+	restoreCmd.Flag("dir", "Directory of the backup to restore.").Required().ExistingDirVar(&r.BackupDir)
+	restoreCmd.Flag("prune", "Also delete the rule groups of the namespaces that are not part of the backup. By default only the namespaces of the backup are restored.").BoolVar(&r.RestorePrune)
+	restoreCmd.Flag("output-format", "Format of the restore summary: <text|json|yaml>").Default(textOutputFormat).EnumVar(&r.OutputFormat, outputFormats...)
+	restoreCmd.Flag("dry-run", "Print the changes that would be applied without applying them.").Short('n').BoolVar(&r.DryRun)
+	restoreCmd.Flag("verbose", "show a unified diff of every changed rule and group field when printing the changes").BoolVar(&r.Verbose)
+	restoreCmd.Flag("strict-expr-comparison", "Compare rule expressions as text, instead of comparing their parsed form which ignores formatting differences.").BoolVar(&r.StrictExprComparison)
+	restoreCmd.Flag("max-deletions", "Abort the restore if it would delete more than this number of rule groups. 0 means no limit.").Default("0").IntVar(&r.MaxDeletions)
+	restoreCmd.Flag("max-deletions-percent", "Abort the restore if it would delete more than this percentage of the rule groups currently stored. 0 means no limit.").Default("0").Float64Var(&r.MaxDeletionsPercent)
+	restoreCmd.Flag("allow-empty", "Allow restoring a backup without any rule group.").BoolVar(&r.AllowEmpty)
+	restoreCmd.Flag("yes", "Apply the changes without asking for confirmation. Confirmation is only asked for when stdin is a terminal.").Short('y').BoolVar(&r.AutoApprove)
+	restoreCmd.Flag("concurrency", "Maximum number of rule group changes applied concurrently.").Default("1").IntVar(&r.SyncConcurrency)
+	restoreCmd.Flag("continue-on-error", "Keep applying the remaining rule group changes when one of them fails, instead of stopping at the first failure.").BoolVar(&r.ContinueOnError)
+	restoreCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+	restoreCmd.Flag("exit-code", fmt.Sprintf("exit with status %d if any changes were applied", ChangesDetectedExitCode)).BoolVar(&r.ExitCode)
 
 	// Prepare Command
 	prepareCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
@@ -212,6 +395,47 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 	).Short('i').BoolVar(&r.InPlaceEdit)
 	prepareCmd.Flag("label", "label to include as part of the aggregations.").Default(defaultPrepareAggregationLabel).Short('l').StringVar(&r.AggregationLabel)
 	prepareCmd.Flag("label-excluded-rule-groups", "Comma separated list of rule group names to exclude when including the configured label to aggregations.").StringVar(&r.AggregationLabelExcludedRuleGroups)
+	prepareCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+
+	// Cost Command
+	costCmd.Arg("rule-files", "The rule files to estimate. If none is set, the rules of the tenant are used.").ExistingFilesVar(&r.RuleFilesList)
+	costCmd.Flag("namespaces", "comma-separated list of namespaces to estimate. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	costCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	costCmd.Flag("rule-files", "The rule files to estimate. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	costCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	costCmd.Flag("evaluation-interval", "Evaluation interval of the rule groups that don't set one.").Default("1m").DurationVar(&r.CostEvaluationInterval)
+	costCmd.Flag("max-utilization", "Share of its evaluation interval a rule group may spend evaluating its rules before it is flagged as likely to miss evaluations.").Default("0.8").Float64Var(&r.CostMaxUtilization)
+	costCmd.Flag("output-format", "Format of the cost estimates: <text|json|yaml>").Default(textOutputFormat).EnumVar(&r.OutputFormat, outputFormats...)
+
+	// Graph Command
+	graphCmd.Arg("rule-files", "The rule files to graph.").ExistingFilesVar(&r.RuleFilesList)
+	graphCmd.Flag("rule-files", "The rule files to graph. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	graphCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	graphCmd.Flag("output-format", "Format of the graph: dot or json.").Default("dot").EnumVar(&r.OutputFormat, "dot", "json")
+
+	// Rewrite Command
+	rewriteCmd.Arg("rule-files", "The rule files to rewrite.").ExistingFilesVar(&r.RuleFilesList)
+	rewriteCmd.Flag("rule-files", "The rule files to rewrite. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	rewriteCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	rewriteCmd.Flag("rename-metric", "Renames a metric in every selector and the recording rules recording it, in the form old=new. Flag can be reused to rename multiple metrics.").StringsVar(&r.RewriteRenameMetrics)
+	rewriteCmd.Flag("add-matcher", `Adds a label matcher, such as 'cluster="x"', to every selector that doesn't already have one for the label. Flag can be reused to add multiple matchers.`).StringsVar(&r.RewriteAddMatchers)
+	rewriteCmd.Flag("drop-label", "Removes a label from the grouping and vector matching clauses. Flag can be reused to drop multiple labels.").StringsVar(&r.RewriteDropLabels)
+	rewriteCmd.Flag("rename-label", "Renames a label in selectors, grouping and vector matching clauses and label_replace/label_join arguments, in the form old=new. Flag can be reused to rename multiple labels.").StringsVar(&r.RewriteRenameLabels)
+	rewriteCmd.Flag("dry-run", "Prints the changes without writing any file.").Short('n').BoolVar(&r.RewriteDryRun)
+	rewriteCmd.Flag(
+		"in-place",
+		"edits the rule file in place",
+	).Short('i').BoolVar(&r.InPlaceEdit)
+	rewriteCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
 
 	// Lint Command
 	lintCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
@@ -230,10 +454,87 @@ func (r *RuleCommand) Register(app *kingpin.Application) {
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
 	checkCmd.Flag("strict", "fails rules checks that do not match best practices exactly").BoolVar(&r.Strict)
+	checkCmd.Flag("fix", "Renames the recording rules that don't match the level:metric:operations format after their expression, and rewrites the rules referencing them.").BoolVar(&r.CheckFix)
+	checkCmd.Flag(
+		"in-place",
+		"edits the rule files in place when fixing recording rule names",
+	).Short('i').BoolVar(&r.InPlaceEdit)
+	checkCmd.Flag("policy-file", "Policy file configuring the organization checks to enforce on the rules.").ExistingFileVar(&r.PolicyFile)
+	checkCmd.Flag("evaluation-interval", "Evaluation interval of the rule groups that don't set one, used to check the range of rate() expressions.").Default("1m").DurationVar(&r.CheckEvaluationInterval)
+	checkCmd.Flag("address", "Address of the cortex cluster whose metric metadata is used to check the type of the metrics used by expressions, alternatively set CORTEX_ADDRESS.").
+		Envar("CORTEX_ADDRESS").
+		StringVar(&r.ClientConfig.Address)
+	checkCmd.Flag("id", "Cortex tenant id, alternatively set CORTEX_TENANT_ID.").
+		Envar("CORTEX_TENANT_ID").
+		StringVar(&r.ClientConfig.ID)
+
+	// Test Command
+	testCmd.Arg("test-files", "The unit test files to run.").Required().ExistingFilesVar(&r.TestFilesList)
+
+	// Backtest Command
+	backtestCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
+	backtestCmd.Flag("namespaces", "comma-separated list of namespaces to backtest. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	backtestCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a backtest. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	backtestCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	backtestCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	backtestCmd.Flag("window", "Length of the time window over which the alerting rules are evaluated.").Default("24h").DurationVar(&r.BacktestWindow)
+	backtestCmd.Flag("end", "End of the time window in RFC3339 format. Defaults to now.").StringVar(&r.BacktestEnd)
+	backtestCmd.Flag("evaluation-interval", "Evaluation interval of the rule groups that don't set one.").Default("1m").DurationVar(&r.BacktestEvaluationInterval)
+	backtestCmd.Flag("flap-window", "An alert firing again within this duration after resolving is counted as a flap.").Default("30m").DurationVar(&r.BacktestFlapWindow)
+	backtestCmd.Flag("output-format", "Format of the backtest results: <text|json|yaml>").Default(textOutputFormat).EnumVar(&r.OutputFormat, outputFormats...)
+
+	// Backfill Command
+	backfillCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
+	backfillCmd.Flag("namespaces", "comma-separated list of namespaces to backfill. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	backfillCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a backfill. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	backfillCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	backfillCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	backfillCmd.Flag("start", "Start of the time range to backfill in RFC3339 format.").Required().StringVar(&r.BackfillStart)
+	backfillCmd.Flag("end", "End of the time range to backfill in RFC3339 format. Defaults to now.").StringVar(&r.BackfillEnd)
+	backfillCmd.Flag("evaluation-interval", "Evaluation interval of the rule groups that don't set one.").Default("1m").DurationVar(&r.BackfillEvaluationInterval)
+	backfillCmd.Flag("tsdb-path", "Path to the folder where to store the TSDB blocks.").Default("data").StringVar(&r.BackfillTSDBPath)
 
 	// List Command
 	listCmd.Flag("format", "Backend type to interact with: <json|yaml|table>").Default("table").EnumVar(&r.Format, formats...)
 	listCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+	listCmd.Flag("health", "Show the evaluation health, last evaluation and firing alerts of every rule group.").BoolVar(&r.ListHealth)
+	listCmd.Flag("unhealthy", "Only show the rule groups with rules that failed or were not evaluated yet. Implies --health.").BoolVar(&r.Unhealthy)
+
+	// Copy Command
+	copyCmd.Flag("source-address", "Address of the cortex cluster to copy the rules from. Defaults to --address, in which case the credentials and TLS settings of the destination are used unless overridden by the other --source flags.").StringVar(&r.CopySource.Address)
+	copyCmd.Flag("source-id", "Tenant id to copy the rules from.").Required().StringVar(&r.CopySource.ID)
+	copyCmd.Flag("source-user", "API user to use when contacting the source cluster. If empty, --source-id will be used instead.").StringVar(&r.CopySource.User)
+	copyCmd.Flag("source-key", "API key to use when contacting the source cluster.").StringVar(&r.CopySource.Key)
+	copyCmd.Flag("source-auth-token", "Authentication token for bearer token or JWT auth of the source cluster.").StringVar(&r.CopySource.AuthToken)
+	copyCmd.Flag("source-use-legacy-routes", "If set, API requests to the source cluster will use the legacy /api/prom/ routes.").BoolVar(&r.CopySource.UseLegacyRoutes)
+	copyCmd.Flag("source-tls-ca-path", "TLS CA certificate to verify the source cluster API as part of mTLS.").StringVar(&r.CopySource.TLS.CAPath)
+	copyCmd.Flag("source-tls-cert-path", "TLS client certificate to authenticate with the source cluster API as part of mTLS.").StringVar(&r.CopySource.TLS.CertPath)
+	copyCmd.Flag("source-tls-key-path", "TLS client certificate private key to authenticate with the source cluster API as part of mTLS.").StringVar(&r.CopySource.TLS.KeyPath)
+	copyCmd.Flag("namespaces", "comma-separated list of source namespaces to copy. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	copyCmd.Flag("ignored-namespaces", "comma-separated list of source namespaces not to copy. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	r.CopyRenames = map[string]string{}
+	copyCmd.Flag("rename-namespace", "Copy a source namespace to a namespace of another name, in source=destination format. Flag can be repeated.").StringMapVar(&r.CopyRenames)
+	copyCmd.Flag("output-format", "Format of the copy summary: <text|json|yaml>").Default(textOutputFormat).EnumVar(&r.OutputFormat, outputFormats...)
+	copyCmd.Flag("dry-run", "Print the changes that would be applied without applying them.").Short('n').BoolVar(&r.DryRun)
+	copyCmd.Flag("verbose", "show a unified diff of every changed rule and group field when printing the changes").BoolVar(&r.Verbose)
+	copyCmd.Flag("strict-expr-comparison", "Compare rule expressions as text, instead of comparing their parsed form which ignores formatting differences.").BoolVar(&r.StrictExprComparison)
+	copyCmd.Flag("max-deletions", "Abort the copy if it would delete more than this number of rule groups. 0 means no limit.").Default("0").IntVar(&r.MaxDeletions)
+	copyCmd.Flag("max-deletions-percent", "Abort the copy if it would delete more than this percentage of the rule groups currently stored in the copied namespaces. 0 means no limit.").Default("0").Float64Var(&r.MaxDeletionsPercent)
+	copyCmd.Flag("yes", "Apply the changes without asking for confirmation. Confirmation is only asked for when stdin is a terminal.").Short('y').BoolVar(&r.AutoApprove)
+	copyCmd.Flag("concurrency", "Maximum number of rule group changes applied concurrently.").Default("1").IntVar(&r.SyncConcurrency)
+	copyCmd.Flag("continue-on-error", "Keep applying the remaining rule group changes when one of them fails, instead of stopping at the first failure.").BoolVar(&r.ContinueOnError)
+	copyCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+	copyCmd.Flag("exit-code", fmt.Sprintf("exit with status %d if any changes were applied", ChangesDetectedExitCode)).BoolVar(&r.ExitCode)
+
+	// Status Command
+	statusCmd.Flag("format", "Output format of the rule group statuses: <json|yaml|table>").Default("table").EnumVar(&r.Format, formats...)
+	statusCmd.Flag("unhealthy", "Only show the rules that failed or were not evaluated yet.").BoolVar(&r.Unhealthy)
 }
 
 func (r *RuleCommand) setup(k *kingpin.ParseContext) error {
@@ -247,6 +548,20 @@ func (r *RuleCommand) setup(k *kingpin.ParseContext) error {
 		r.ClientConfig.UseLegacyRoutes = true
 	}
 
+	if r.ValuesFile != "" {
+		values, err := rules.LoadValues(r.ValuesFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to load values")
+		}
+		r.templateValues = values
+	}
+
+	overlays, err := rules.LoadOverlays(r.OverlayFiles)
+	if err != nil {
+		return errors.Wrap(err, "unable to load overlays")
+	}
+	r.overlays = overlays
+
 	cli, err := client.New(r.ClientConfig)
 	if err != nil {
 		return err
@@ -298,40 +613,77 @@ func (r *RuleCommand) setupFiles() error {
 		}
 	}
 
+	r.staticRuleFiles = append([]string{}, r.RuleFilesList...)
+	for _, dir := range r.ruleDirs() {
+		files, err := findRuleFiles(dir)
+		if err != nil {
+			return err
+		}
+		r.RuleFilesList = append(r.RuleFilesList, files...)
+	}
+
+	return nil
+}
+
+// ruleDirs returns the directories of --rule-dirs.
+func (r *RuleCommand) ruleDirs() []string {
+	var dirs []string
 	for _, dir := range strings.Split(r.RuleFilesPath, ",") {
 		if dir != "" {
-			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if info.IsDir() {
-					return nil
-				}
-
-				if strings.HasSuffix(info.Name(), ".yml") || strings.HasSuffix(info.Name(), ".yaml") {
-					log.WithFields(log.Fields{
-						"file": info.Name(),
-						"path": path,
-					}).Debugf("adding file in rule-path")
-					r.RuleFilesList = append(r.RuleFilesList, path)
-					return nil
-				}
-				log.WithFields(log.Fields{
-					"file": info.Name(),
-					"path": path,
-				}).Debugf("ignorings file in rule-path")
-				return nil
-			})
-			if err != nil {
-				return fmt.Errorf("error walking the path %q: %v", dir, err)
-			}
+			dirs = append(dirs, dir)
 		}
 	}
+	return dirs
+}
 
-	return nil
+// findRuleFiles returns the files of a directory tree with a .yml or .yaml suffix.
+func findRuleFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		if strings.HasSuffix(info.Name(), ".yml") || strings.HasSuffix(info.Name(), ".yaml") {
+			log.WithFields(log.Fields{
+				"file": info.Name(),
+				"path": path,
+			}).Debugf("adding file in rule-path")
+			files = append(files, path)
+			return nil
+		}
+		log.WithFields(log.Fields{
+			"file": info.Name(),
+			"path": path,
+		}).Debugf("ignorings file in rule-path")
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking the path %q: %v", dir, err)
+	}
+	return files, nil
 }
 
 func (r *RuleCommand) listRules(k *kingpin.ParseContext) error {
+	if r.ListHealth || r.Unhealthy {
+		groups, err := r.rulesHealth(context.Background())
+		if err != nil {
+			return err
+		}
+
+		p := printer.New(r.DisableColor)
+		if r.Format != "table" {
+			// Rules are only listed by the status command.
+			for i := range groups {
+				groups[i].Rules = nil
+			}
+		}
+		return p.PrintGroupHealth(groups, r.Format, os.Stdout)
+	}
+
 	rules, err := r.cli.ListRules(context.Background(), "")
 	if err != nil {
 		log.Fatalf("unable to read rules from cortex, %v", err)
@@ -343,7 +695,7 @@ func (r *RuleCommand) listRules(k *kingpin.ParseContext) error {
 }
 
 func (r *RuleCommand) printRules(k *kingpin.ParseContext) error {
-	rules, err := r.cli.ListRules(context.Background(), "")
+	groups, err := r.cli.ListRules(context.Background(), "")
 	if err != nil {
 		if err == client.ErrResourceNotFound {
 			log.Infof("no rule groups currently exist for this user")
@@ -352,8 +704,29 @@ func (r *RuleCommand) printRules(k *kingpin.ParseContext) error {
 		log.Fatalf("unable to read rules from cortex, %v", err)
 	}
 
+	if r.RuleFormat == rules.PrometheusRuleFormat {
+		return printPrometheusRules(groups)
+	}
+
 	p := printer.New(r.DisableColor)
-	return p.PrintRuleGroups(rules)
+	return p.PrintRuleGroups(groups)
+}
+
+// printPrometheusRules prints rule groups as PrometheusRule resources, one per
+// namespace.
+func printPrometheusRules(groups map[string][]rwrulefmt.RuleGroup) error {
+	nss := make([]rules.RuleNamespace, 0, len(groups))
+	for namespace, grps := range groups {
+		nss = append(nss, rules.RuleNamespace{Namespace: namespace, Groups: grps})
+	}
+	sort.Slice(nss, func(i, j int) bool { return nss[i].Namespace < nss[j].Namespace })
+
+	payload, err := rules.MarshalPrometheusRules(nss)
+	if err != nil {
+		return errors.Wrap(err, "unable to convert rules to PrometheusRule resources")
+	}
+	_, err = os.Stdout.Write(payload)
+	return err
 }
 
 func (r *RuleCommand) getRuleGroup(k *kingpin.ParseContext) error {
@@ -379,7 +752,7 @@ func (r *RuleCommand) deleteRuleGroup(k *kingpin.ParseContext) error {
 }
 
 func (r *RuleCommand) loadRules(k *kingpin.ParseContext) error {
-	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	nss, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
 	if err != nil {
 		return errors.Wrap(err, "load operation unsuccessful, unable to parse rules files")
 	}
@@ -393,7 +766,7 @@ func (r *RuleCommand) loadRules(k *kingpin.ParseContext) error {
 				return errors.Wrap(err, "load operation unsuccessful, unable to contact cortex api")
 			}
 			if curGroup != nil {
-				err = rules.CompareGroups(*curGroup, group)
+				err = rules.CompareGroupsWithOptions(*curGroup, group, r.compareOptions())
 				if err == nil {
 					log.WithFields(log.Fields{
 						"group":     group.Name,
@@ -441,7 +814,7 @@ func (r *RuleCommand) diffRules(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "diff operation unsuccessful, unable to load rules files")
 	}
 
-	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	nss, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
 	if err != nil {
 		return errors.Wrap(err, "diff operation unsuccessful, unable to parse rules files")
 	}
@@ -454,48 +827,19 @@ func (r *RuleCommand) diffRules(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "diff operation unsuccessful, unable to contact cortex api")
 	}
 
-	changes := []rules.NamespaceChange{}
-
-	for _, ns := range nss {
-		if !r.shouldCheckNamespace(ns.Namespace) {
-			continue
-		}
+	changes := computeNamespaceChanges(nss, currentNamespaceMap, r.shouldCheckNamespace, r.compareOptions())
 
-		currentNamespace, exists := currentNamespaceMap[ns.Namespace]
-		if !exists {
-			changes = append(changes, rules.NamespaceChange{
-				State:         rules.Created,
-				Namespace:     ns.Namespace,
-				GroupsCreated: ns.Groups,
-			})
-			continue
-		}
-
-		origNamespace := rules.RuleNamespace{
-			Namespace: ns.Namespace,
-			Groups:    currentNamespace,
-		}
-
-		changes = append(changes, rules.CompareNamespaces(origNamespace, ns))
-
-		// Remove namespace from temp map so namespaces that have been removed can easily be detected
-		delete(currentNamespaceMap, ns.Namespace)
+	p := printer.New(r.DisableColor)
+	if r.OutputFormat != textOutputFormat {
+		err = p.PrintChangeReport(rules.NewChangeReport(changes), r.OutputFormat, os.Stdout)
+	} else {
+		err = p.PrintComparisonResult(changes, r.Verbose)
 	}
-
-	for ns, deletedGroups := range currentNamespaceMap {
-		if !r.shouldCheckNamespace(ns) {
-			continue
-		}
-
-		changes = append(changes, rules.NamespaceChange{
-			State:         rules.Deleted,
-			Namespace:     ns,
-			GroupsDeleted: deletedGroups,
-		})
+	if err != nil {
+		return err
 	}
 
-	p := printer.New(r.DisableColor)
-	return p.PrintComparisonResult(changes, r.Verbose)
+	return r.changesExitCode(changes)
 }
 
 func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
@@ -504,7 +848,23 @@ func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to load rules files")
 	}
 
-	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	if r.TenantsDir != "" {
+		if len(r.RuleFilesList) > 0 {
+			return errors.New("rule files cannot be set together with --tenants-dir")
+		}
+		if r.Watch {
+			return errors.New("--watch cannot be set together with --tenants-dir")
+		}
+		return r.syncTenants(context.Background())
+	}
+	if r.ClientConfig.ID == "" {
+		return errors.New("required flag --id not provided")
+	}
+	if r.Watch {
+		return r.watchRules(context.Background())
+	}
+
+	nss, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
 	if err != nil {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to parse rules files")
 	}
@@ -517,14 +877,93 @@ func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to contact cortex api")
 	}
 
+	return r.syncNamespaces(context.Background(), "sync", nss, currentNamespaceMap, r.shouldCheckNamespace)
+}
+
+// syncNamespaces applies the changes required for the namespaces checked by
+// shouldCheck to match the desired ones, after checking them against the sync
+// guards and printing them for confirmation. The operation names the command
+// in error messages.
+func (r *RuleCommand) syncNamespaces(ctx context.Context, operation string, desired map[string]rules.RuleNamespace, current map[string][]rwrulefmt.RuleGroup, shouldCheck func(namespace string) bool) error {
+	changes := computeNamespaceChanges(desired, current, shouldCheck, r.compareOptions())
+
+	guards := syncGuards{
+		maxDeletions:        r.MaxDeletions,
+		maxDeletionsPercent: r.MaxDeletionsPercent,
+		allowEmpty:          r.AllowEmpty,
+	}
+	if err := guards.check(desired, current, changes, shouldCheck); err != nil {
+		return errors.Wrap(err, operation+" operation aborted")
+	}
+
+	if r.DryRun {
+		log.Infof("dry run, no changes will be applied")
+		p := printer.New(r.DisableColor)
+		var err error
+		if r.OutputFormat != textOutputFormat {
+			err = p.PrintChangeReport(rules.NewChangeReport(changes), r.OutputFormat, os.Stdout)
+		} else {
+			err = p.PrintComparisonResult(changes, r.Verbose)
+		}
+		if err != nil {
+			return err
+		}
+		return r.changesExitCode(changes)
+	}
+
+	if !r.AutoApprove && isTerminal(os.Stdin) {
+		approved, err := r.confirmChanges(changes)
+		if err != nil {
+			return errors.Wrap(err, operation+" operation unsuccessful, unable to read confirmation")
+		}
+		if !approved {
+			return errors.New(operation + " operation aborted, changes were not confirmed")
+		}
+	}
+
+	if err := r.executeChanges(ctx, changes); err != nil {
+		return errors.Wrap(err, operation+" operation unsuccessful, unable to complete executing changes.")
+	}
+
+	return r.changesExitCode(changes)
+}
+
+// confirmChanges prints the changes about to be applied and asks for an
+// interactive confirmation. It returns true without asking if there are no changes.
+func (r *RuleCommand) confirmChanges(changes []rules.NamespaceChange) (bool, error) {
+	created, updated, deleted := rules.SummarizeChanges(changes)
+	if created+updated+deleted == 0 {
+		return true, nil
+	}
+
+	// Keep stdout clean for the machine-readable report.
+	out := os.Stdout
+	if r.OutputFormat != textOutputFormat {
+		out = os.Stderr
+	} else {
+		p := printer.New(r.DisableColor)
+		if err := p.PrintComparisonResult(changes, r.Verbose); err != nil {
+			return false, err
+		}
+		fmt.Println()
+	}
+
+	question := fmt.Sprintf("Do you want to create %d, update %d and delete %d rule groups?", created, updated, deleted)
+	return confirm(os.Stdin, out, question)
+}
+
+// computeNamespaceChanges returns the changes required to turn the current rule
+// set into the desired one. Namespaces for which shouldCheck returns false are
+// left out. Changes are sorted by namespace.
+func computeNamespaceChanges(desired map[string]rules.RuleNamespace, current map[string][]rwrulefmt.RuleGroup, shouldCheck func(namespace string) bool, opts rules.CompareOptions) []rules.NamespaceChange {
 	changes := []rules.NamespaceChange{}
 
-	for _, ns := range nss {
-		if !r.shouldCheckNamespace(ns.Namespace) {
+	for _, ns := range desired {
+		if !shouldCheck(ns.Namespace) {
 			continue
 		}
 
-		currentNamespace, exists := currentNamespaceMap[ns.Namespace]
+		currentNamespace, exists := current[ns.Namespace]
 		if !exists {
 			changes = append(changes, rules.NamespaceChange{
 				State:         rules.Created,
@@ -539,14 +978,12 @@ func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
 			Groups:    currentNamespace,
 		}
 
-		changes = append(changes, rules.CompareNamespaces(origNamespace, ns))
-
-		// Remove namespace from temp map so namespaces that have been removed can easily be detected
-		delete(currentNamespaceMap, ns.Namespace)
+		changes = append(changes, rules.CompareNamespacesWithOptions(origNamespace, ns, opts))
 	}
 
-	for ns, deletedGroups := range currentNamespaceMap {
-		if !r.shouldCheckNamespace(ns) {
+	for ns, deletedGroups := range current {
+		// Namespaces that are still part of the desired rule set have already been compared.
+		if _, exists := desired[ns]; exists || !shouldCheck(ns) {
 			continue
 		}
 
@@ -557,67 +994,48 @@ func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
 		})
 	}
 
-	err = r.executeChanges(context.Background(), changes)
-	if err != nil {
-		return errors.Wrap(err, "sync operation unsuccessful, unable to complete executing changes.")
-	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Namespace < changes[j].Namespace
+	})
 
-	return nil
+	return changes
 }
 
-func (r *RuleCommand) executeChanges(ctx context.Context, changes []rules.NamespaceChange) error {
-	var err error
-	for _, ch := range changes {
-		for _, g := range ch.GroupsCreated {
-			if !r.shouldCheckNamespace(ch.Namespace) {
-				continue
-			}
-
-			log.WithFields(log.Fields{
-				"group":     g.Name,
-				"namespace": ch.Namespace,
-			}).Infof("creating group")
-			err = r.cli.CreateRuleGroup(ctx, ch.Namespace, g)
-			if err != nil {
-				return err
-			}
-		}
+// changesExitCode returns a ChangesDetectedError if --exit-code is set and
+// the set of changes is not empty.
+func (r *RuleCommand) changesExitCode(changes []rules.NamespaceChange) error {
+	if !r.ExitCode {
+		return nil
+	}
 
-		for _, g := range ch.GroupsUpdated {
-			if !r.shouldCheckNamespace(ch.Namespace) {
-				continue
-			}
+	created, updated, deleted := rules.SummarizeChanges(changes)
+	if created+updated+deleted > 0 {
+		return ChangesDetectedError{}
+	}
 
-			log.WithFields(log.Fields{
-				"group":     g.New.Name,
-				"namespace": ch.Namespace,
-			}).Infof("updating group")
-			err = r.cli.CreateRuleGroup(ctx, ch.Namespace, g.New)
-			if err != nil {
-				return err
-			}
-		}
+	return nil
+}
 
-		for _, g := range ch.GroupsDeleted {
-			if !r.shouldCheckNamespace(ch.Namespace) {
-				continue
-			}
+func (r *RuleCommand) executeChanges(ctx context.Context, changes []rules.NamespaceChange) error {
+	results, applyErr := applyChanges(ctx, r.cli, changes, r.SyncConcurrency, r.ContinueOnError)
 
-			log.WithFields(log.Fields{
-				"group":     g.Name,
-				"namespace": ch.Namespace,
-			}).Infof("deleting group")
-			err = r.cli.DeleteRuleGroup(ctx, ch.Namespace, g.Name)
-			if err != nil && err != client.ErrResourceNotFound {
-				return err
-			}
+	p := printer.New(r.DisableColor)
+	if r.OutputFormat != textOutputFormat {
+		report := rules.NewChangeReport(changes).WithResults(results)
+		if err := p.PrintChangeReport(report, r.OutputFormat, os.Stdout); err != nil {
+			return err
 		}
+		return applyErr
 	}
 
-	updated, created, deleted := rules.SummarizeChanges(changes)
+	p.PrintSyncResults(results)
+
+	// Only the changes that were applied are counted as created, updated or
+	// deleted.
+	created, updated, deleted, failed, skipped := rules.SummarizeResults(results)
 	fmt.Println()
-	fmt.Printf("Sync Summary: %v Groups Created, %v Groups Updated, %v Groups Deleted\n", created, updated, deleted)
-	return nil
+	fmt.Printf("Sync Summary: %v Groups Created, %v Groups Updated, %v Groups Deleted, %v Groups Failed, %v Groups Skipped\n", created, updated, deleted, failed, skipped)
+	return applyErr
 }
 
 func (r *RuleCommand) prepare(k *kingpin.ParseContext) error {
@@ -626,7 +1044,7 @@ func (r *RuleCommand) prepare(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to load rules files")
 	}
 
-	namespaces, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	namespaces, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
 	if err != nil {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to parse rules files")
 	}
@@ -637,23 +1055,34 @@ func (r *RuleCommand) prepare(k *kingpin.ParseContext) error {
 		return !excluded
 	}
 
-	var count, mod int
+	var count int
+	var changes []rules.ExprChange
 	for _, ruleNamespace := range namespaces {
-		c, m, err := ruleNamespace.AggregateBy(r.AggregationLabel, applyTo)
+		c, ch, err := ruleNamespace.AggregateBy(r.AggregationLabel, applyTo)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "prepare operation unsuccessful, unable to aggregate namespace %s by %s", ruleNamespace.Namespace, r.AggregationLabel)
 		}
 
 		count += c
-		mod += m
+		changes = append(changes, ch...)
 	}
 
 	// now, save all the files
+	if err := r.checkRendered(r.InPlaceEdit); err != nil {
+		return err
+	}
 	if err := save(namespaces, r.InPlaceEdit); err != nil {
 		return err
 	}
 
-	log.Infof("SUCCESS: %d rules found, %d modified expressions", count, mod)
+	// Namespaces are printed in order, rules keep the order of their file.
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Namespace < changes[j].Namespace
+	})
+	p := printer.New(r.DisableColor)
+	p.PrintExprChanges(changes)
+
+	log.Infof("SUCCESS: %d rules found, %d modified expressions", count, len(changes))
 
 	return nil
 }
@@ -664,7 +1093,7 @@ func (r *RuleCommand) lint(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to load rules files")
 	}
 
-	namespaces, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	namespaces, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
 	if err != nil {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to parse rules files")
 	}
@@ -682,6 +1111,9 @@ func (r *RuleCommand) lint(k *kingpin.ParseContext) error {
 
 	if !r.LintDryRun {
 		// linting will always in-place edit unless is a dry-run.
+		if err := r.checkRendered(true); err != nil {
+			return errors.Wrap(err, "use --dry-run to lint rendered rule files")
+		}
 		if err := save(namespaces, true); err != nil {
 			return err
 		}
@@ -692,22 +1124,118 @@ func (r *RuleCommand) lint(k *kingpin.ParseContext) error {
 	return nil
 }
 
+// fixRecordingRuleNames renames the misnamed recording rules of the namespaces
+// and saves the rule files. Rules that can't be renamed are left for the check
+// to report.
+func (r *RuleCommand) fixRecordingRuleNames(namespaces map[string]rules.RuleNamespace) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("fixing recording rule names is not supported for the %s backend", r.Backend)
+	}
+
+	fix, err := rules.FixRecordingRules(namespaces, r.Strict)
+	if err != nil {
+		return errors.Wrap(err, "check operation unsuccessful, unable to fix recording rule names")
+	}
+
+	if len(fix.Renames) > 0 {
+		if err := r.checkRendered(r.InPlaceEdit); err != nil {
+			return err
+		}
+
+		p := printer.New(r.DisableColor)
+		if err := p.PrintRecordingRuleRenames(fix.Renames, os.Stdout); err != nil {
+			return err
+		}
+		if err := save(namespaces, r.InPlaceEdit); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("%d recording rules renamed, %d modified rules, %d recording rules not fixed", len(fix.Renames), len(fix.Changes), fix.Unfixed)
+	return nil
+}
+
 func (r *RuleCommand) checkRecordingRuleNames(k *kingpin.ParseContext) error {
 	err := r.setupFiles()
 	if err != nil {
 		return errors.Wrap(err, "check operation unsuccessful, unable to load rules files")
 	}
 
-	namespaces, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	namespaces, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
 	if err != nil {
 		return errors.Wrap(err, "check operation unsuccessful, unable to parse rules files")
 	}
 
+	if r.CheckFix {
+		if err := r.fixRecordingRuleNames(namespaces); err != nil {
+			return err
+		}
+	}
+
+	var policy *rules.Policy
+	if r.PolicyFile != "" {
+		policy, err = rules.LoadPolicy(r.PolicyFile)
+		if err != nil {
+			return errors.Wrap(err, "check operation unsuccessful, unable to load policy file")
+		}
+	}
+
+	var analyzer *rules.ExprAnalyzer
+	if r.Backend == rules.CortexBackend {
+		analyzer = &rules.ExprAnalyzer{DefaultInterval: r.CheckEvaluationInterval}
+		if r.ClientConfig.Address != "" {
+			analyzer.MetricTypes, err = r.cli.MetricTypes(context.Background())
+			if err != nil {
+				return errors.Wrap(err, "check operation unsuccessful, unable to fetch metric metadata")
+			}
+		}
+	}
+
+	var violations, templateErrors int
 	for _, ruleNamespace := range namespaces {
 		n := ruleNamespace.CheckRecordingRules(r.Strict)
 		if n != 0 {
 			return fmt.Errorf("%d erroneous recording rule names", n)
 		}
+
+		findings := rules.CheckTemplates(ruleNamespace)
+		for _, f := range findings {
+			log.WithFields(log.Fields{
+				"file":      f.File,
+				"namespace": f.Namespace,
+				"ruleGroup": f.Group,
+				"rule":      f.Rule,
+				"check":     f.Check,
+			}).Errorln(f.Message)
+		}
+		templateErrors += len(findings)
+
+		if analyzer != nil {
+			for _, f := range analyzer.Analyze(ruleNamespace) {
+				log.WithFields(log.Fields{
+					"file":      f.File,
+					"namespace": f.Namespace,
+					"ruleGroup": f.Group,
+					"rule":      f.Rule,
+					"check":     f.Check,
+				}).Warnln(f.Message)
+			}
+		}
+
+		if policy != nil {
+			findings := policy.Check(ruleNamespace)
+			for _, f := range findings {
+				log.WithFields(log.Fields{
+					"file":      f.File,
+					"namespace": f.Namespace,
+					"ruleGroup": f.Group,
+					"rule":      f.Rule,
+					"check":     f.Check,
+				}).Errorln(f.Message)
+			}
+			violations += len(findings)
+		}
+
 		duplicateRules := checkDuplicates(ruleNamespace.Groups)
 		if len(duplicateRules) != 0 {
 			fmt.Printf("%d duplicate rule(s) found.\n", len(duplicateRules))
@@ -721,6 +1249,144 @@ func (r *RuleCommand) checkRecordingRuleNames(k *kingpin.ParseContext) error {
 		}
 	}
 
+	if templateErrors != 0 {
+		return fmt.Errorf("%d template errors", templateErrors)
+	}
+
+	if violations != 0 {
+		return fmt.Errorf("%d policy violations", violations)
+	}
+
+	return nil
+}
+
+func (r *RuleCommand) testRules(k *kingpin.ParseContext) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("unit testing rules is not supported for the %s backend", r.Backend)
+	}
+
+	if !unittest.Run(os.Stdout, r.TestFilesList...) {
+		return errors.New("one or more rule unit tests failed")
+	}
+
+	return nil
+}
+
+func (r *RuleCommand) backtestRules(k *kingpin.ParseContext) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("backtesting rules is not supported for the %s backend", r.Backend)
+	}
+
+	if r.BacktestWindow <= 0 || r.BacktestEvaluationInterval <= 0 {
+		return errors.New("--window and --evaluation-interval must be greater than 0")
+	}
+
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "backtest operation unsuccessful, unable to load rules files")
+	}
+
+	nss, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
+	if err != nil {
+		return errors.Wrap(err, "backtest operation unsuccessful, unable to parse rules files")
+	}
+	for name := range nss {
+		if !r.shouldCheckNamespace(name) {
+			delete(nss, name)
+		}
+	}
+
+	end := time.Now()
+	if r.BacktestEnd != "" {
+		end, err = time.Parse(time.RFC3339, r.BacktestEnd)
+		if err != nil {
+			return errors.Wrap(err, "backtest operation unsuccessful, invalid --end")
+		}
+	}
+	end = end.Truncate(time.Second)
+
+	results := rules.Backtest(context.Background(), r.cli, nss, rules.BacktestOptions{
+		Start:           end.Add(-r.BacktestWindow),
+		End:             end,
+		DefaultInterval: r.BacktestEvaluationInterval,
+		FlapWindow:      r.BacktestFlapWindow,
+	})
+
+	p := printer.New(r.DisableColor)
+	if err := p.PrintBacktestResults(results, r.OutputFormat, os.Stdout); err != nil {
+		return err
+	}
+
+	var failed int
+	for _, res := range results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d alerting rules could not be backtested", failed, len(results))
+	}
+
+	return nil
+}
+
+func (r *RuleCommand) backfillRules(k *kingpin.ParseContext) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("backfilling rules is not supported for the %s backend", r.Backend)
+	}
+
+	start, err := time.Parse(time.RFC3339, r.BackfillStart)
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, invalid --start")
+	}
+	end := time.Now()
+	if r.BackfillEnd != "" {
+		end, err = time.Parse(time.RFC3339, r.BackfillEnd)
+		if err != nil {
+			return errors.Wrap(err, "backfill operation unsuccessful, invalid --end")
+		}
+	}
+	if !start.Before(end) {
+		return errors.New("backfill operation unsuccessful, --start must be before --end")
+	}
+	if r.BackfillEvaluationInterval <= 0 {
+		return errors.New("--evaluation-interval must be greater than 0")
+	}
+
+	err = r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to load rules files")
+	}
+
+	nss, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to parse rules files")
+	}
+	for name := range nss {
+		if !r.shouldCheckNamespace(name) {
+			delete(nss, name)
+		}
+	}
+
+	matrix, err := rules.EvaluateRecordingRules(context.Background(), r.cli, nss, start, end, r.BackfillEvaluationInterval)
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful")
+	}
+
+	if err := os.MkdirAll(r.BackfillTSDBPath, 0755); err != nil {
+		return err
+	}
+
+	log.Infof("Store TSDB blocks in '%s'", r.BackfillTSDBPath)
+	iterator := func() backfill.Iterator {
+		return backfill.NewMatrixIterator(matrix)
+	}
+	mint := model.TimeFromUnixNano(start.UnixNano())
+	maxt := model.TimeFromUnixNano(end.UnixNano())
+	if err := backfill.CreateBlocks(iterator, int64(mint), int64(maxt), 1000, r.BackfillTSDBPath, true, os.Stdout); err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to create blocks")
+	}
+
 	return nil
 }
 
@@ -730,6 +1396,226 @@ type compareRuleType struct {
 	label  map[string]string
 }
 
+func (r *RuleCommand) costRules(k *kingpin.ParseContext) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("estimating the cost of rules is not supported for the %s backend", r.Backend)
+	}
+
+	if r.CostEvaluationInterval <= 0 || r.CostMaxUtilization <= 0 {
+		return errors.New("--evaluation-interval and --max-utilization must be greater than 0")
+	}
+
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "cost operation unsuccessful, unable to load rules files")
+	}
+
+	var nss map[string]rules.RuleNamespace
+	if len(r.RuleFilesList) > 0 {
+		nss, err = rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
+		if err != nil {
+			return errors.Wrap(err, "cost operation unsuccessful, unable to parse rules files")
+		}
+	} else {
+		current, err := r.cli.ListRules(context.Background(), "")
+		if err != nil {
+			return errors.Wrap(err, "cost operation unsuccessful, unable to fetch rules")
+		}
+		nss = make(map[string]rules.RuleNamespace, len(current))
+		for name, groups := range current {
+			nss[name] = rules.RuleNamespace{Namespace: name, Groups: groups}
+		}
+	}
+	for name := range nss {
+		if !r.shouldCheckNamespace(name) {
+			delete(nss, name)
+		}
+	}
+
+	results := rules.EstimateCosts(context.Background(), r.cli, nss, rules.CostOptions{
+		Time:            time.Now(),
+		DefaultInterval: r.CostEvaluationInterval,
+		MaxUtilization:  r.CostMaxUtilization,
+	})
+
+	p := printer.New(r.DisableColor)
+	if err := p.PrintCostResults(results, r.OutputFormat, os.Stdout); err != nil {
+		return err
+	}
+
+	var atRisk int
+	for _, res := range results {
+		if res.AtRisk {
+			atRisk++
+		}
+	}
+	if atRisk > 0 {
+		return fmt.Errorf("%d of %d rule groups are likely to miss evaluations", atRisk, len(results))
+	}
+
+	return nil
+}
+
+func (r *RuleCommand) graphRules(k *kingpin.ParseContext) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("graphing rules is not supported for the %s backend", r.Backend)
+	}
+
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "graph operation unsuccessful, unable to load rules files")
+	}
+
+	namespaces, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
+	if err != nil {
+		return errors.Wrap(err, "graph operation unsuccessful, unable to parse rules files")
+	}
+
+	graph := rules.BuildDependencyGraph(namespaces)
+	for _, f := range graph.Check() {
+		log.WithFields(log.Fields{
+			"file":      f.File,
+			"namespace": f.Namespace,
+			"ruleGroup": f.Group,
+			"rule":      f.Rule,
+			"check":     f.Check,
+		}).Warnln(f.Message)
+	}
+
+	p := printer.New(r.DisableColor)
+	return p.PrintDependencyGraph(graph, r.OutputFormat, os.Stdout)
+}
+
+func (r *RuleCommand) rewriteRules(k *kingpin.ParseContext) error {
+	if r.Backend != rules.CortexBackend {
+		return fmt.Errorf("rewriting rules is not supported for the %s backend", r.Backend)
+	}
+
+	var (
+		rewriter rules.Rewriter
+		err      error
+	)
+	if rewriter.RenameMetrics, err = rules.ParseRenames(r.RewriteRenameMetrics); err != nil {
+		return errors.Wrap(err, "rewrite operation unsuccessful, invalid --rename-metric")
+	}
+	if rewriter.AddMatchers, err = rules.ParseMatchers(r.RewriteAddMatchers); err != nil {
+		return errors.Wrap(err, "rewrite operation unsuccessful, invalid --add-matcher")
+	}
+	if rewriter.RenameLabels, err = rules.ParseRenames(r.RewriteRenameLabels); err != nil {
+		return errors.Wrap(err, "rewrite operation unsuccessful, invalid --rename-label")
+	}
+	rewriter.DropLabels = r.RewriteDropLabels
+
+	err = r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "rewrite operation unsuccessful, unable to load rules files")
+	}
+
+	namespaces, err := rules.ParseFilesWithOptions(r.Backend, r.RuleFilesList, r.parseOptions())
+	if err != nil {
+		return errors.Wrap(err, "rewrite operation unsuccessful, unable to parse rules files")
+	}
+
+	var count int
+	var changes []rules.ExprChange
+	for _, ruleNamespace := range namespaces {
+		c, ch, err := rewriter.Rewrite(ruleNamespace)
+		if err != nil {
+			return errors.Wrapf(err, "rewrite operation unsuccessful, unable to rewrite namespace %s", ruleNamespace.Namespace)
+		}
+
+		count += c
+		changes = append(changes, ch...)
+	}
+
+	// Namespaces are printed in order, rules keep the order of their file.
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Namespace < changes[j].Namespace
+	})
+	p := printer.New(r.DisableColor)
+	p.PrintExprChanges(changes)
+
+	if !r.RewriteDryRun {
+		if err := r.checkRendered(r.InPlaceEdit); err != nil {
+			return err
+		}
+		if err := save(namespaces, r.InPlaceEdit); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("SUCCESS: %d rules found, %d modified rules", count, len(changes))
+
+	return nil
+}
+
+func (r *RuleCommand) backupRules(k *kingpin.ParseContext) error {
+	groups, err := r.cli.ListRules(context.Background(), "")
+	if err != nil && err != client.ErrResourceNotFound {
+		return errors.Wrap(err, "backup operation unsuccessful, unable to read rules from cortex")
+	}
+
+	manifest, err := rules.WriteBackup(r.BackupDir, groups, rules.BackupManifest{
+		Tenant:    r.ClientConfig.ID,
+		Address:   r.ClientConfig.Address,
+		Backend:   r.Backend,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "backup operation unsuccessful, unable to write backup")
+	}
+
+	var count int
+	for _, ns := range manifest.Namespaces {
+		count += ns.Groups
+	}
+	log.Infof("SUCCESS: %d rule groups of %d namespaces backed up to %s", count, len(manifest.Namespaces), r.BackupDir)
+
+	return nil
+}
+
+// restoreRules syncs the rules of a backup. Unless --prune is set, only the
+// namespaces of the backup are synced.
+func (r *RuleCommand) restoreRules(k *kingpin.ParseContext) error {
+	manifest, err := rules.ReadBackupManifest(r.BackupDir)
+	if err != nil {
+		return errors.Wrap(err, "restore operation unsuccessful")
+	}
+
+	if manifest.Backend != "" && manifest.Backend != r.Backend {
+		return fmt.Errorf("restore operation unsuccessful, the backup is for the %s backend", manifest.Backend)
+	}
+	if manifest.Tenant != r.ClientConfig.ID {
+		log.WithFields(log.Fields{
+			"backup_tenant": manifest.Tenant,
+			"tenant":        r.ClientConfig.ID,
+		}).Warnln("restoring the backup of another tenant")
+	}
+
+	log.WithFields(log.Fields{
+		"tenant":     manifest.Tenant,
+		"address":    manifest.Address,
+		"timestamp":  manifest.Timestamp,
+		"namespaces": len(manifest.Namespaces),
+	}).Infof("restoring backup")
+
+	// Backups hold the rendered rules, as stored in the ruler.
+	r.RuleFormat = rules.CortextoolFormat
+	r.templateValues = nil
+	r.overlays = nil
+	r.RuleFilesList = nil
+	backedUp := map[string]struct{}{}
+	for _, ns := range manifest.Namespaces {
+		r.RuleFilesList = append(r.RuleFilesList, ns.File)
+		backedUp[ns.Namespace] = struct{}{}
+	}
+	if !r.RestorePrune {
+		r.namespacesMap = backedUp
+	}
+
+	return r.syncRules(k)
+}
+
 func checkDuplicates(groups []rwrulefmt.RuleGroup) []compareRuleType {
 	var duplicates []compareRuleType
 
@@ -762,16 +1648,59 @@ func ruleMetric(rule rulefmt.RuleNode) string {
 
 // End taken from https://github.com/prometheus/prometheus/blob/8c8de46003d1800c9d40121b4a5e5de8582ef6e1/cmd/promtool/main.go#L403
 
+// compareOptions returns the options to compare rule groups with.
+func (r *RuleCommand) compareOptions() rules.CompareOptions {
+	return rules.CompareOptions{
+		Backend:              r.Backend,
+		StrictExprComparison: r.StrictExprComparison,
+	}
+}
+
+// parseOptions returns the options to parse the rule files with.
+func (r *RuleCommand) parseOptions() rules.ParseOptions {
+	return rules.ParseOptions{
+		Format:            r.RuleFormat,
+		NamespaceTemplate: r.NamespaceTemplate,
+		Values:            r.templateValues,
+		Overlays:          r.overlays,
+	}
+}
+
+// checkRendered returns an error if rule files rendered with values or overlays
+// would be overwritten, losing their templates.
+func (r *RuleCommand) checkRendered(inPlace bool) error {
+	if inPlace && (r.templateValues != nil || len(r.overlays) > 0) {
+		return errors.New("rule files rendered with --values or --overlay cannot be edited in place")
+	}
+	return nil
+}
+
 // save saves a set of rule files to to disk. You can specify whenever you want the
 // file(s) to be edited in-place.
 func save(nss map[string]rules.RuleNamespace, i bool) error {
+	// Files are updated with the namespaces parsed from them, PrometheusRule
+	// resources in particular may share their file with other resources.
+	files := map[string][]rules.RuleNamespace{}
 	for _, ns := range nss {
-		payload, err := yamlv3.Marshal(ns)
+		files[ns.Filepath] = append(files[ns.Filepath], ns)
+	}
+
+	for filepath, nss := range files {
+		content, err := os.ReadFile(filepath)
 		if err != nil {
 			return err
 		}
 
-		filepath := ns.Filepath
+		var payload []byte
+		if nss[0].Source != nil {
+			payload, err = rules.UpdatePrometheusRules(content, nss)
+		} else {
+			payload, err = rules.UpdateRuleFile(content, nss)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unable to update %s", filepath)
+		}
+
 		if !i {
 			filepath = filepath + ".result"
 		}
@@ -783,3 +1712,27 @@ func save(nss map[string]rules.RuleNamespace, i bool) error {
 
 	return nil
 }
+
+func (r *RuleCommand) rulesStatus(k *kingpin.ParseContext) error {
+	groups, err := r.rulesHealth(context.Background())
+	if err != nil {
+		return err
+	}
+
+	p := printer.New(r.DisableColor)
+	return p.PrintRuleHealth(groups, r.Format, os.Stdout)
+}
+
+// rulesHealth returns the evaluation state of the rule groups of the tenant,
+// only keeping the unhealthy rules if requested.
+func (r *RuleCommand) rulesHealth(ctx context.Context) ([]rules.GroupHealth, error) {
+	groups, err := r.cli.RulesHealth(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read rules health from cortex")
+	}
+
+	if r.Unhealthy {
+		groups = rules.Unhealthy(groups)
+	}
+	return groups, nil
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/cortex-tools/pkg/client"
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// copyRules copies the rules of the source tenant to the tenant, applying only
+// the differences to the copied namespaces.
+func (r *RuleCommand) copyRules(k *kingpin.ParseContext) error {
+	if err := r.setupFiles(); err != nil {
+		return errors.Wrap(err, "copy operation unsuccessful")
+	}
+
+	cfg := r.copySourceConfig()
+	if cfg.Address == r.ClientConfig.Address && cfg.ID == r.ClientConfig.ID && len(r.CopyRenames) == 0 {
+		return errors.New("copy operation unsuccessful, the source and destination are the same tenant")
+	}
+
+	src, err := client.New(cfg)
+	if err != nil {
+		return errors.Wrap(err, "copy operation unsuccessful, unable to create source client")
+	}
+
+	ctx := context.Background()
+	source, err := src.ListRules(ctx, "")
+	if err != nil && err != client.ErrResourceNotFound {
+		return errors.Wrap(err, "copy operation unsuccessful, unable to read rules from the source")
+	}
+
+	desired, err := copyNamespaces(source, r.shouldCheckNamespace, r.CopyRenames)
+	if err != nil {
+		return errors.Wrap(err, "copy operation unsuccessful")
+	}
+	if len(desired) == 0 {
+		return errors.New("copy operation unsuccessful, no namespace to copy")
+	}
+
+	current, err := r.cli.ListRules(ctx, "")
+	if err != nil && err != client.ErrResourceNotFound {
+		return errors.Wrap(err, "copy operation unsuccessful, unable to contact cortex api")
+	}
+
+	log.WithFields(log.Fields{
+		"source_address": cfg.Address,
+		"source_tenant":  cfg.ID,
+		"namespaces":     len(desired),
+	}).Infof("copying rules")
+
+	// Only the copied namespaces are synced, other namespaces of the
+	// destination are kept.
+	copied := func(namespace string) bool {
+		_, ok := desired[namespace]
+		return ok
+	}
+	return r.syncNamespaces(ctx, "copy", desired, current, copied)
+}
+
+// copySourceConfig returns the client config of the source of a copy. Unless
+// its address is set, the source is in the destination cluster and uses the
+// config of the destination, except for the settings of the source flags.
+func (r *RuleCommand) copySourceConfig() client.Config {
+	src := r.CopySource
+	if r.Backend == rules.LokiBackend {
+		src.UseLegacyRoutes = true
+	}
+	if src.Address != "" {
+		return src
+	}
+
+	cfg := r.ClientConfig
+	cfg.ID = src.ID
+	if src.User != "" {
+		cfg.User = src.User
+	}
+	if src.Key != "" {
+		cfg.Key = src.Key
+	}
+	if src.AuthToken != "" {
+		cfg.AuthToken = src.AuthToken
+	}
+	if src.UseLegacyRoutes {
+		cfg.UseLegacyRoutes = true
+	}
+	if src.TLS.CAPath != "" {
+		cfg.TLS.CAPath = src.TLS.CAPath
+	}
+	if src.TLS.CertPath != "" {
+		cfg.TLS.CertPath = src.TLS.CertPath
+	}
+	if src.TLS.KeyPath != "" {
+		cfg.TLS.KeyPath = src.TLS.KeyPath
+	}
+	return cfg
+}
+
+// copyNamespaces returns the namespaces of the source rules to copy, renamed
+// with the renames mapping source namespaces to destination ones.
+func copyNamespaces(source map[string][]rwrulefmt.RuleGroup, shouldCopy func(namespace string) bool, renames map[string]string) (map[string]rules.RuleNamespace, error) {
+	for from := range renames {
+		if _, ok := source[from]; !ok {
+			log.WithField("namespace", from).Warnln("renamed namespace not found in the source")
+		}
+	}
+
+	names := make([]string, 0, len(source))
+	for namespace := range source {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+
+	copied := map[string]rules.RuleNamespace{}
+	sources := map[string]string{}
+	for _, namespace := range names {
+		if !shouldCopy(namespace) {
+			continue
+		}
+
+		target := namespace
+		if to, ok := renames[namespace]; ok {
+			target = to
+		}
+		if other, ok := sources[target]; ok {
+			return nil, fmt.Errorf("namespaces %s and %s are both copied to namespace %s", other, namespace, target)
+		}
+		sources[target] = namespace
+
+		copied[target] = rules.RuleNamespace{Namespace: target, Groups: source[namespace]}
+	}
+	return copied, nil
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/cortex-tools/pkg/client"
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestCopyNamespaces(t *testing.T) {
+	group := func(name string) []rwrulefmt.RuleGroup {
+		return []rwrulefmt.RuleGroup{{RuleGroup: rulefmt.RuleGroup{Name: name}}}
+	}
+	source := map[string][]rwrulefmt.RuleGroup{
+		"api":     group("a"),
+		"web":     group("w"),
+		"ignored": group("i"),
+	}
+	shouldCopy := func(namespace string) bool { return namespace != "ignored" }
+
+	copied, err := copyNamespaces(source, shouldCopy, map[string]string{"api": "team-api", "missing": "other"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]rules.RuleNamespace{
+		"team-api": {Namespace: "team-api", Groups: group("a")},
+		"web":      {Namespace: "web", Groups: group("w")},
+	}, copied)
+
+	_, err = copyNamespaces(source, shouldCopy, map[string]string{"api": "web"})
+	require.EqualError(t, err, "namespaces api and web are both copied to namespace web")
+
+	copied, err = copyNamespaces(nil, shouldCopy, nil)
+	require.NoError(t, err)
+	require.Empty(t, copied)
+}
+
+func TestCopySourceConfig(t *testing.T) {
+	r := RuleCommand{
+		Backend: rules.CortexBackend,
+		ClientConfig: client.Config{
+			Address:      "https://dst.example.com",
+			ID:           "dst",
+			User:         "dst-user",
+			Key:          "dst-key",
+			ExtraHeaders: map[string]string{"X-Scope": "1"},
+		},
+	}
+
+	// Copying within the destination cluster reuses its config.
+	r.CopySource = client.Config{ID: "src", Key: "src-key"}
+	require.Equal(t, client.Config{
+		Address:      "https://dst.example.com",
+		ID:           "src",
+		User:         "dst-user",
+		Key:          "src-key",
+		ExtraHeaders: map[string]string{"X-Scope": "1"},
+	}, r.copySourceConfig())
+
+	// Another cluster only uses the source flags.
+	r.CopySource = client.Config{Address: "https://src.example.com", ID: "src", AuthToken: "token"}
+	require.Equal(t, client.Config{Address: "https://src.example.com", ID: "src", AuthToken: "token"}, r.copySourceConfig())
+
+	r.Backend = rules.LokiBackend
+	require.True(t, r.copySourceConfig().UseLegacyRoutes)
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/cortex-tools/pkg/client"
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// ruleGroupWriter is the subset of the cortex client used to apply rule changes.
+type ruleGroupWriter interface {
+	CreateRuleGroup(ctx context.Context, namespace string, rg rwrulefmt.RuleGroup) error
+	DeleteRuleGroup(ctx context.Context, namespace, groupName string) error
+}
+
+// groupOperation is a single create, update or delete of a rule group.
+type groupOperation struct {
+	namespace string
+	change    rules.NamespaceState
+	group     rwrulefmt.RuleGroup
+}
+
+// groupOperations flattens a set of namespace changes into the list of rule
+// group operations required to apply them.
+func groupOperations(changes []rules.NamespaceChange) []groupOperation {
+	var ops []groupOperation
+	for _, ch := range changes {
+		for _, g := range ch.GroupsCreated {
+			ops = append(ops, groupOperation{namespace: ch.Namespace, change: rules.Created, group: g})
+		}
+		for _, g := range ch.GroupsUpdated {
+			ops = append(ops, groupOperation{namespace: ch.Namespace, change: rules.Updated, group: g.New})
+		}
+		for _, g := range ch.GroupsDeleted {
+			ops = append(ops, groupOperation{namespace: ch.Namespace, change: rules.Deleted, group: g})
+		}
+	}
+	return ops
+}
+
+func (op groupOperation) apply(ctx context.Context, cli ruleGroupWriter) error {
+	logger := log.WithFields(log.Fields{
+		"group":     op.group.Name,
+		"namespace": op.namespace,
+	})
+
+	switch op.change {
+	case rules.Created:
+		logger.Infof("creating group")
+		return cli.CreateRuleGroup(ctx, op.namespace, op.group)
+	case rules.Updated:
+		logger.Infof("updating group")
+		return cli.CreateRuleGroup(ctx, op.namespace, op.group)
+	case rules.Deleted:
+		logger.Infof("deleting group")
+		err := cli.DeleteRuleGroup(ctx, op.namespace, op.group.Name)
+		if err != nil && err != client.ErrResourceNotFound {
+			return err
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported change %v", op.change)
+	}
+}
+
+// validateConcurrency validates the --concurrency flag of the commands
+// applying rule group changes.
+func (r *RuleCommand) validateConcurrency(_ *kingpin.CmdClause) error {
+	if r.SyncConcurrency < 1 {
+		return fmt.Errorf("--concurrency must be greater than 0, got %d", r.SyncConcurrency)
+	}
+	return nil
+}
+
+// applyChanges applies every group operation of the changes with up to
+// concurrency concurrent requests, concurrency must be greater than 0. Unless continueOnError is set, no new
+// operation is started once one has failed; operations that were never
+// attempted are reported as skipped. The returned results follow the order of
+// the changes, the error is non-nil if any operation failed.
+func applyChanges(ctx context.Context, cli ruleGroupWriter, changes []rules.NamespaceChange, concurrency int, continueOnError bool) ([]rules.GroupResult, error) {
+	ops := groupOperations(changes)
+	results := make([]rules.GroupResult, len(ops))
+	for i, op := range ops {
+		results[i] = rules.GroupResult{
+			Namespace: op.namespace,
+			Group:     op.group.Name,
+			Change:    op.change,
+			Status:    rules.GroupResultSkipped,
+		}
+	}
+
+	jobs := make(chan int, len(ops))
+	for i := range ops {
+		jobs <- i
+	}
+	close(jobs)
+
+	var failed atomic.Int64
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency && w < len(ops); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				if ctx.Err() != nil || (!continueOnError && failed.Load() > 0) {
+					continue
+				}
+
+				// Every worker writes to a distinct index, so the results don't need a lock.
+				if err := ops[i].apply(ctx, cli); err != nil {
+					log.WithError(err).WithFields(log.Fields{
+						"group":     ops[i].group.Name,
+						"namespace": ops[i].namespace,
+					}).Errorf("unable to apply rule group change")
+
+					failed.Add(1)
+					results[i].Status = rules.GroupResultFailed
+					results[i].Error = err.Error()
+					continue
+				}
+				results[i].Status = rules.GroupResultSucceeded
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := failed.Load(); n > 0 {
+		return results, fmt.Errorf("%d of %d rule group changes failed", n, len(ops))
+	}
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// syncGuards are the safety checks run against the computed changes before a
+// sync applies anything.
+type syncGuards struct {
+	// maxDeletions is the maximum number of rule groups a sync may delete, 0 means no limit.
+	maxDeletions int
+	// maxDeletionsPercent is the maximum percentage of the currently stored rule
+	// groups a sync may delete, 0 means no limit.
+	maxDeletionsPercent float64
+	// allowEmpty allows syncing a local rule set without any rule group.
+	allowEmpty bool
+}
+
+// check returns an error if the changes required to sync the desired rule set
+// violate one of the guards. Only namespaces for which shouldCheck returns
+// true are taken into account.
+func (g syncGuards) check(desired map[string]rules.RuleNamespace, current map[string][]rwrulefmt.RuleGroup, changes []rules.NamespaceChange, shouldCheck func(namespace string) bool) error {
+	if !g.allowEmpty {
+		var localGroups int
+		for _, ns := range desired {
+			if shouldCheck(ns.Namespace) {
+				localGroups += len(ns.Groups)
+			}
+		}
+		if localGroups == 0 {
+			return errors.New("the local rule set is empty, syncing it would delete every rule group; use --allow-empty if this is intended")
+		}
+	}
+
+	_, _, deleted := rules.SummarizeChanges(changes)
+	if deleted == 0 {
+		return nil
+	}
+
+	if g.maxDeletions > 0 && deleted > g.maxDeletions {
+		return fmt.Errorf("the sync would delete %d rule groups, more than the maximum of %d allowed by --max-deletions", deleted, g.maxDeletions)
+	}
+
+	if g.maxDeletionsPercent > 0 {
+		var remoteGroups int
+		for ns, groups := range current {
+			if shouldCheck(ns) {
+				remoteGroups += len(groups)
+			}
+		}
+
+		percent := float64(deleted) / float64(remoteGroups) * 100
+		if percent > g.maxDeletionsPercent {
+			return fmt.Errorf("the sync would delete %d of %d rule groups (%.1f%%), more than the maximum of %.1f%% allowed by --max-deletions-percent", deleted, remoteGroups, percent, g.maxDeletionsPercent)
+		}
+	}
+
+	return nil
+}
+
+// isTerminal returns whether the file is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks a yes/no question and reads the answer from in. Only "y" and
+// "yes" are accepted as a confirmation.
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/cortex-tools/pkg/client"
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+type fakeRuleGroupWriter struct {
+	mtx     sync.Mutex
+	failing map[string]error
+	applied []string
+}
+
+func (f *fakeRuleGroupWriter) CreateRuleGroup(_ context.Context, namespace string, rg rwrulefmt.RuleGroup) error {
+	return f.record("create", namespace, rg.Name)
+}
+
+func (f *fakeRuleGroupWriter) DeleteRuleGroup(_ context.Context, namespace, groupName string) error {
+	return f.record("delete", namespace, groupName)
+}
+
+func (f *fakeRuleGroupWriter) record(action, namespace, group string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if err, ok := f.failing[namespace+"/"+group]; ok {
+		return err
+	}
+	f.applied = append(f.applied, action+" "+namespace+"/"+group)
+	return nil
+}
+
+func TestApplyChanges(t *testing.T) {
+	group := func(name string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}}
+	}
+	changes := []rules.NamespaceChange{
+		{
+			Namespace:     "ns1",
+			State:         rules.Created,
+			GroupsCreated: []rwrulefmt.RuleGroup{group("a"), group("b")},
+		},
+		{
+			Namespace:     "ns2",
+			State:         rules.Updated,
+			GroupsUpdated: []rules.UpdatedRuleGroup{{Original: group("c"), New: group("c")}},
+			GroupsDeleted: []rwrulefmt.RuleGroup{group("d"), group("e")},
+		},
+	}
+
+	statuses := func(results []rules.GroupResult) map[string]string {
+		m := map[string]string{}
+		for _, r := range results {
+			m[r.Namespace+"/"+r.Group] = r.Status
+		}
+		return m
+	}
+
+	t.Run("all changes succeed", func(t *testing.T) {
+		cli := &fakeRuleGroupWriter{
+			// Deleting a group that no longer exists is not a failure.
+			failing: map[string]error{"ns2/e": client.ErrResourceNotFound},
+		}
+		results, err := applyChanges(context.Background(), cli, changes, 4, false)
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		assert.ElementsMatch(t, []string{"create ns1/a", "create ns1/b", "create ns2/c", "delete ns2/d"}, cli.applied)
+		for _, r := range results {
+			assert.Equal(t, rules.GroupResultSucceeded, r.Status)
+		}
+		assert.Equal(t, rules.Updated, results[2].Change)
+		assert.Equal(t, rules.Deleted, results[4].Change)
+	})
+
+	t.Run("continue on error", func(t *testing.T) {
+		cli := &fakeRuleGroupWriter{
+			failing: map[string]error{"ns1/b": errors.New("boom")},
+		}
+		results, err := applyChanges(context.Background(), cli, changes, 4, true)
+		require.EqualError(t, err, "1 of 5 rule group changes failed")
+		assert.Equal(t, map[string]string{
+			"ns1/a": rules.GroupResultSucceeded,
+			"ns1/b": rules.GroupResultFailed,
+			"ns2/c": rules.GroupResultSucceeded,
+			"ns2/d": rules.GroupResultSucceeded,
+			"ns2/e": rules.GroupResultSucceeded,
+		}, statuses(results))
+		assert.Equal(t, "boom", results[1].Error)
+
+		created, updated, deleted, failed, skipped := rules.SummarizeResults(results)
+		assert.Equal(t, []int{1, 1, 2, 1, 0}, []int{created, updated, deleted, failed, skipped})
+	})
+
+	t.Run("stop on first error", func(t *testing.T) {
+		cli := &fakeRuleGroupWriter{
+			failing: map[string]error{"ns1/b": errors.New("boom")},
+		}
+		results, err := applyChanges(context.Background(), cli, changes, 1, false)
+		require.EqualError(t, err, "1 of 5 rule group changes failed")
+		assert.Equal(t, map[string]string{
+			"ns1/a": rules.GroupResultSucceeded,
+			"ns1/b": rules.GroupResultFailed,
+			"ns2/c": rules.GroupResultSkipped,
+			"ns2/d": rules.GroupResultSkipped,
+			"ns2/e": rules.GroupResultSkipped,
+		}, statuses(results))
+
+		created, updated, deleted, failed, skipped := rules.SummarizeResults(results)
+		assert.Equal(t, []int{1, 0, 0, 1, 3}, []int{created, updated, deleted, failed, skipped})
+	})
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	r := &RuleCommand{SyncConcurrency: 4}
+	require.NoError(t, r.validateConcurrency(nil))
+
+	r.SyncConcurrency = 0
+	require.EqualError(t, r.validateConcurrency(nil), "--concurrency must be greater than 0, got 0")
+}
+
+func TestSyncGuards(t *testing.T) {
+	group := func(name string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}}
+	}
+	current := map[string][]rwrulefmt.RuleGroup{
+		"ns1": {group("a"), group("b")},
+		"ns2": {group("c"), group("d")},
+	}
+	all := func(string) bool { return true }
+
+	tests := []struct {
+		name        string
+		guards      syncGuards
+		desired     map[string]rules.RuleNamespace
+		expectedErr string
+	}{
+		{
+			name:        "empty local rule set",
+			desired:     map[string]rules.RuleNamespace{},
+			expectedErr: "the local rule set is empty, syncing it would delete every rule group; use --allow-empty if this is intended",
+		},
+		{
+			name:    "empty local rule set allowed",
+			guards:  syncGuards{allowEmpty: true},
+			desired: map[string]rules.RuleNamespace{},
+		},
+		{
+			name:   "deletions below the limit",
+			guards: syncGuards{maxDeletions: 2, maxDeletionsPercent: 50},
+			desired: map[string]rules.RuleNamespace{
+				"ns1": {Namespace: "ns1", Groups: []rwrulefmt.RuleGroup{group("a"), group("b")}},
+			},
+		},
+		{
+			name:   "too many deletions",
+			guards: syncGuards{maxDeletions: 1},
+			desired: map[string]rules.RuleNamespace{
+				"ns1": {Namespace: "ns1", Groups: []rwrulefmt.RuleGroup{group("a"), group("b")}},
+			},
+			expectedErr: "the sync would delete 2 rule groups, more than the maximum of 1 allowed by --max-deletions",
+		},
+		{
+			name:   "too large percentage of deletions",
+			guards: syncGuards{maxDeletionsPercent: 50},
+			desired: map[string]rules.RuleNamespace{
+				"ns1": {Namespace: "ns1", Groups: []rwrulefmt.RuleGroup{group("a")}},
+			},
+			expectedErr: "the sync would delete 3 of 4 rule groups (75.0%), more than the maximum of 50.0% allowed by --max-deletions-percent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := computeNamespaceChanges(tt.desired, current, all, rules.CompareOptions{})
+			err := tt.guards.check(tt.desired, current, changes, all)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for input, expected := range map[string]bool{
+		"y\n":   true,
+		"YES\n": true,
+		"n\n":   false,
+		"\n":    false,
+		"":      false,
+	} {
+		var out bytes.Buffer
+		approved, err := confirm(strings.NewReader(input), &out, "Apply?")
+		require.NoError(t, err)
+		assert.Equal(t, expected, approved, "input %q", input)
+		assert.Equal(t, "Apply? [y/N]: ", out.String())
+	}
+}
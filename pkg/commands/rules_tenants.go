@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/grafana/cortex-tools/pkg/client"
+	"github.com/grafana/cortex-tools/pkg/printer"
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// tenantRuleClient is the subset of the cortex client used to sync the rules
+// of a tenant.
+type tenantRuleClient interface {
+	ruleGroupWriter
+	ListRules(ctx context.Context, namespace string) (map[string][]rwrulefmt.RuleGroup, error)
+}
+
+// tenantSync is the sync of the rules of a single tenant of a tenants
+// directory.
+type tenantSync struct {
+	tenant      string
+	cli         tenantRuleClient
+	desired     map[string]rules.RuleNamespace
+	current     map[string][]rwrulefmt.RuleGroup
+	shouldCheck func(namespace string) bool
+
+	changes []rules.NamespaceChange
+	results []rules.GroupResult
+	err     error
+}
+
+// syncTenants syncs the rules of every tenant of the tenants directory. The
+// changes of all the tenants are computed and checked against the sync guards
+// before any of them is applied.
+func (r *RuleCommand) syncTenants(ctx context.Context) error {
+	if r.ClientConfig.ID != "" {
+		log.WithField("id", r.ClientConfig.ID).Warnln("--id is ignored when syncing a tenants directory")
+	}
+
+	syncs, err := r.loadTenants(r.TenantsDir)
+	if err != nil {
+		return errors.Wrap(err, "sync operation unsuccessful, unable to load tenants")
+	}
+
+	for _, s := range syncs {
+		cfg := r.ClientConfig
+		cfg.ID = s.tenant
+		cli, err := client.New(cfg)
+		if err != nil {
+			return errors.Wrapf(err, "sync operation unsuccessful, unable to create client for tenant %s", s.tenant)
+		}
+		s.cli = cli
+	}
+
+	if failed := computeTenantChanges(ctx, syncs, r.TenantConcurrency, r.compareOptions()); failed > 0 {
+		return fmt.Errorf("sync operation unsuccessful, unable to compute the changes of %d of %d tenants", failed, len(syncs))
+	}
+
+	guards := syncGuards{
+		maxDeletions:        r.MaxDeletions,
+		maxDeletionsPercent: r.MaxDeletionsPercent,
+		allowEmpty:          r.AllowEmpty,
+	}
+	var changes []rules.NamespaceChange
+	for _, s := range syncs {
+		if err := guards.check(s.desired, s.current, s.changes, s.shouldCheck); err != nil {
+			return errors.Wrapf(err, "sync operation aborted for tenant %s", s.tenant)
+		}
+		changes = append(changes, s.changes...)
+	}
+
+	p := printer.New(r.DisableColor)
+	if r.DryRun {
+		log.Infof("dry run, no changes will be applied")
+		if err := r.printTenantChanges(p, syncs); err != nil {
+			return err
+		}
+		return r.changesExitCode(changes)
+	}
+
+	if !r.AutoApprove && isTerminal(os.Stdin) {
+		created, updated, deleted := rules.SummarizeChanges(changes)
+		if created+updated+deleted > 0 {
+			if r.OutputFormat == textOutputFormat {
+				if err := r.printTenantChanges(p, syncs); err != nil {
+					return err
+				}
+				fmt.Println()
+			}
+
+			question := fmt.Sprintf("Do you want to create %d, update %d and delete %d rule groups of %d tenants?", created, updated, deleted, len(syncs))
+			approved, err := confirm(os.Stdin, os.Stderr, question)
+			if err != nil {
+				return errors.Wrap(err, "sync operation unsuccessful, unable to read confirmation")
+			}
+			if !approved {
+				return errors.New("sync operation aborted, changes were not confirmed")
+			}
+		}
+	}
+
+	failed := applyTenantChanges(ctx, syncs, r.TenantConcurrency, r.SyncConcurrency, r.ContinueOnError)
+
+	reports := tenantReports(syncs)
+	if r.OutputFormat != textOutputFormat {
+		if err := p.PrintMultiTenantReport(rules.NewMultiTenantReport(reports), r.OutputFormat, os.Stdout); err != nil {
+			return err
+		}
+	} else {
+		for _, s := range syncs {
+			if len(s.results) > 0 {
+				fmt.Printf("Tenant %s\n", s.tenant)
+				p.PrintSyncResults(s.results)
+				fmt.Println()
+			}
+		}
+		if err := p.PrintTenantSummary(reports, os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("sync operation unsuccessful, unable to complete executing the changes of %d of %d tenants", failed, len(syncs))
+	}
+	return r.changesExitCode(changes)
+}
+
+// printTenantChanges prints the changes of every tenant, followed by a summary
+// in the text format.
+func (r *RuleCommand) printTenantChanges(p *printer.Printer, syncs []*tenantSync) error {
+	reports := tenantReports(syncs)
+	if r.OutputFormat != textOutputFormat {
+		return p.PrintMultiTenantReport(rules.NewMultiTenantReport(reports), r.OutputFormat, os.Stdout)
+	}
+
+	for _, s := range syncs {
+		fmt.Printf("Tenant %s\n", s.tenant)
+		if err := p.PrintComparisonResult(s.changes, r.Verbose); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	return p.PrintTenantSummary(reports, os.Stdout)
+}
+
+// loadTenants parses the rule files of every tenant of a tenants directory,
+// where each subdirectory holds the rule files of the tenant it is named
+// after. Tenants are sorted by id.
+func (r *RuleCommand) loadTenants(root string) ([]*tenantSync, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var syncs []*tenantSync
+	tenants := map[string]struct{}{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		tenant := entry.Name()
+		files, err := findRuleFiles(filepath.Join(root, tenant))
+		if err != nil {
+			return nil, err
+		}
+
+		nss, err := rules.ParseFilesWithOptions(r.Backend, files, r.parseOptions())
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse rules files of tenant %s", tenant)
+		}
+
+		shouldCheck, err := r.tenantNamespaceFilter(tenant)
+		if err != nil {
+			return nil, err
+		}
+
+		log.WithFields(log.Fields{"tenant": tenant, "files": len(files)}).Debugf("loaded tenant")
+		syncs = append(syncs, &tenantSync{tenant: tenant, desired: nss, shouldCheck: shouldCheck})
+		tenants[tenant] = struct{}{}
+	}
+
+	if len(syncs) == 0 {
+		return nil, fmt.Errorf("no tenant directory found in %s", root)
+	}
+
+	for _, filters := range []map[string]string{r.TenantNamespaces, r.TenantIgnoredNamespaces} {
+		for tenant := range filters {
+			if _, ok := tenants[tenant]; !ok {
+				return nil, fmt.Errorf("namespaces are set for tenant %s which has no directory in %s", tenant, root)
+			}
+		}
+	}
+
+	sort.Slice(syncs, func(i, j int) bool { return syncs[i].tenant < syncs[j].tenant })
+	return syncs, nil
+}
+
+// tenantNamespaceFilter returns whether a namespace of a tenant should be
+// checked. Unless namespaces are set for the tenant, --namespaces and
+// --ignored-namespaces apply.
+func (r *RuleCommand) tenantNamespaceFilter(tenant string) (func(namespace string) bool, error) {
+	allowed, hasAllowed := r.TenantNamespaces[tenant]
+	ignored, hasIgnored := r.TenantIgnoredNamespaces[tenant]
+
+	switch {
+	case hasAllowed && hasIgnored:
+		return nil, fmt.Errorf("--tenant-namespaces and --tenant-ignored-namespaces cannot be set at the same time for tenant %s", tenant)
+	case hasAllowed:
+		set := namespaceSet(allowed)
+		return func(namespace string) bool {
+			_, ok := set[namespace]
+			return ok
+		}, nil
+	case hasIgnored:
+		set := namespaceSet(ignored)
+		return func(namespace string) bool {
+			_, ok := set[namespace]
+			return !ok
+		}, nil
+	default:
+		return r.shouldCheckNamespace, nil
+	}
+}
+
+// namespaceSet returns the set of namespaces of a comma-separated list.
+func namespaceSet(list string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			set[ns] = struct{}{}
+		}
+	}
+	return set
+}
+
+// validateTenantConcurrency validates the concurrency flags of rules sync,
+// including the --tenant-concurrency flag of --tenants-dir.
+func (r *RuleCommand) validateTenantConcurrency(cmd *kingpin.CmdClause) error {
+	if err := r.validateConcurrency(cmd); err != nil {
+		return err
+	}
+	if r.TenantConcurrency < 1 {
+		return fmt.Errorf("--tenant-concurrency must be greater than 0, got %d", r.TenantConcurrency)
+	}
+	return nil
+}
+
+// computeTenantChanges fetches the current rules of every tenant and computes
+// the changes required to sync them, for up to concurrency tenants at once.
+// It returns the number of tenants that failed.
+func computeTenantChanges(ctx context.Context, syncs []*tenantSync, concurrency int, opts rules.CompareOptions) int {
+	return forEachTenant(syncs, concurrency, func(s *tenantSync) error {
+		current, err := s.cli.ListRules(ctx, "")
+		if err != nil && err != client.ErrResourceNotFound {
+			log.WithError(err).WithField("tenant", s.tenant).Errorln("unable to read rules from cortex")
+			return err
+		}
+
+		s.current = current
+		s.changes = computeNamespaceChanges(s.desired, current, s.shouldCheck, opts)
+		return nil
+	})
+}
+
+// applyTenantChanges applies the changes of every tenant, for up to
+// concurrency tenants at once, each with up to groupConcurrency concurrent
+// rule group changes. A tenant failing doesn't stop the others. It returns the
+// number of tenants that failed.
+func applyTenantChanges(ctx context.Context, syncs []*tenantSync, concurrency, groupConcurrency int, continueOnError bool) int {
+	return forEachTenant(syncs, concurrency, func(s *tenantSync) error {
+		results, err := applyChanges(ctx, s.cli, s.changes, groupConcurrency, continueOnError)
+		s.results = results
+		if err != nil {
+			log.WithError(err).WithField("tenant", s.tenant).Errorln("unable to sync rules")
+		}
+		return err
+	})
+}
+
+// forEachTenant runs fn for every tenant, for up to concurrency tenants at
+// once, and records its error. It returns the number of tenants that failed.
+// The concurrency must be greater than 0.
+func forEachTenant(syncs []*tenantSync, concurrency int, fn func(s *tenantSync) error) int {
+	jobs := make(chan *tenantSync, len(syncs))
+	for _, s := range syncs {
+		jobs <- s
+	}
+	close(jobs)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency && w < len(syncs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Every worker updates distinct tenants, so they don't need a lock.
+			for s := range jobs {
+				s.err = fn(s)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed int
+	for _, s := range syncs {
+		if s.err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// tenantReports returns the change report of every tenant.
+func tenantReports(syncs []*tenantSync) []rules.TenantReport {
+	reports := make([]rules.TenantReport, 0, len(syncs))
+	for _, s := range syncs {
+		report := rules.TenantReport{
+			Tenant:       s.tenant,
+			ChangeReport: rules.NewChangeReport(s.changes),
+		}
+		if s.results != nil {
+			report.ChangeReport = report.ChangeReport.WithResults(s.results)
+		}
+		if s.err != nil {
+			report.Error = s.err.Error()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+type fakeTenantRuleClient struct {
+	fakeRuleGroupWriter
+	current map[string][]rwrulefmt.RuleGroup
+	listErr error
+}
+
+func (f *fakeTenantRuleClient) ListRules(_ context.Context, _ string) (map[string][]rwrulefmt.RuleGroup, error) {
+	return f.current, f.listErr
+}
+
+func TestLoadTenants(t *testing.T) {
+	r := &RuleCommand{
+		Backend:          rules.CortexBackend,
+		TenantNamespaces: map[string]string{"team-a": "api"},
+	}
+
+	syncs, err := r.loadTenants("testdata/tenants")
+	require.NoError(t, err)
+	require.Len(t, syncs, 2)
+
+	require.Equal(t, "team-a", syncs[0].tenant)
+	require.Len(t, syncs[0].desired, 2)
+	require.True(t, syncs[0].shouldCheck("api"))
+	require.False(t, syncs[0].shouldCheck("web"))
+
+	require.Equal(t, "team-b", syncs[1].tenant)
+	require.Contains(t, syncs[1].desired, "node")
+	require.True(t, syncs[1].shouldCheck("node"))
+
+	r.TenantNamespaces = map[string]string{"team-c": "api"}
+	_, err = r.loadTenants("testdata/tenants")
+	require.EqualError(t, err, "namespaces are set for tenant team-c which has no directory in testdata/tenants")
+
+	r.TenantNamespaces = map[string]string{"team-a": "api"}
+	r.TenantIgnoredNamespaces = map[string]string{"team-a": "web"}
+	_, err = r.loadTenants("testdata/tenants")
+	require.Error(t, err)
+
+	_, err = r.loadTenants("testdata/tenants/team-a")
+	require.EqualError(t, err, "no tenant directory found in testdata/tenants/team-a")
+}
+
+func TestTenantChanges(t *testing.T) {
+	group := func(name string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}}
+	}
+	checkAll := func(string) bool { return true }
+
+	a := &fakeTenantRuleClient{current: map[string][]rwrulefmt.RuleGroup{"old": {group("old")}}}
+	b := &fakeTenantRuleClient{fakeRuleGroupWriter: fakeRuleGroupWriter{failing: map[string]error{"ns/b": errors.New("boom")}}}
+	syncs := []*tenantSync{
+		{tenant: "a", cli: a, shouldCheck: checkAll, desired: map[string]rules.RuleNamespace{"ns": {Namespace: "ns", Groups: []rwrulefmt.RuleGroup{group("a")}}}},
+		{tenant: "b", cli: b, shouldCheck: checkAll, desired: map[string]rules.RuleNamespace{"ns": {Namespace: "ns", Groups: []rwrulefmt.RuleGroup{group("b")}}}},
+	}
+
+	require.Equal(t, 0, computeTenantChanges(context.Background(), syncs, 2, rules.CompareOptions{}))
+	require.Len(t, syncs[0].changes, 2)
+	require.Len(t, syncs[1].changes, 1)
+
+	require.Equal(t, 1, applyTenantChanges(context.Background(), syncs, 2, 1, false))
+	require.ElementsMatch(t, []string{"create ns/a", "delete old/old"}, a.applied)
+	require.Empty(t, b.applied)
+	require.NoError(t, syncs[0].err)
+	require.EqualError(t, syncs[1].err, "1 of 1 rule group changes failed")
+
+	reports := tenantReports(syncs)
+	require.Equal(t, "a", reports[0].Tenant)
+	require.Equal(t, rules.ChangeSummary{HasChanges: true, GroupsCreated: 1, GroupsDeleted: 1}, reports[0].Summary)
+	require.Empty(t, reports[0].Error)
+	require.Equal(t, rules.ChangeSummary{HasChanges: true, GroupsCreated: 1, GroupsFailed: 1}, reports[1].Summary)
+	require.Equal(t, "1 of 1 rule group changes failed", reports[1].Error)
+
+	// Tenants whose rules can't be read are reported as failed.
+	b.listErr = errors.New("unreachable")
+	require.Equal(t, 1, computeTenantChanges(context.Background(), syncs, 1, rules.CompareOptions{}))
+	require.EqualError(t, syncs[1].err, "unreachable")
+}
+
+func TestValidateTenantConcurrency(t *testing.T) {
+	r := &RuleCommand{SyncConcurrency: 1, TenantConcurrency: 2}
+	require.NoError(t, r.validateTenantConcurrency(nil))
+
+	r.TenantConcurrency = -1
+	require.EqualError(t, r.validateTenantConcurrency(nil), "--tenant-concurrency must be greater than 0, got -1")
+
+	r.SyncConcurrency = 0
+	require.EqualError(t, r.validateTenantConcurrency(nil), "--concurrency must be greater than 0, got 0")
+}
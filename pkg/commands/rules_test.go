@@ -3,6 +3,7 @@ package commands
 import (
 	"testing"
 
+	"github.com/grafana/cortex-tools/pkg/rules"
 	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/assert"
@@ -61,3 +62,37 @@ func TestCheckDuplicates(t *testing.T) {
 		})
 	}
 }
+
+func TestComputeNamespaceChanges(t *testing.T) {
+	group := func(name, expr string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{
+			RuleGroup: rulefmt.RuleGroup{
+				Name: name,
+				Rules: []rulefmt.RuleNode{
+					{Record: yaml.Node{Value: "up:sum"}, Expr: yaml.Node{Value: expr}},
+				},
+			},
+		}
+	}
+
+	desired := map[string]rules.RuleNamespace{
+		"new":       {Namespace: "new", Groups: []rwrulefmt.RuleGroup{group("a", "sum(up)")}},
+		"unchanged": {Namespace: "unchanged", Groups: []rwrulefmt.RuleGroup{group("b", "sum(up)")}},
+		"updated":   {Namespace: "updated", Groups: []rwrulefmt.RuleGroup{group("c", "sum(up)")}},
+		"ignored":   {Namespace: "ignored", Groups: []rwrulefmt.RuleGroup{group("d", "sum(up)")}},
+	}
+	current := map[string][]rwrulefmt.RuleGroup{
+		"unchanged": {group("b", "sum(up)")},
+		"updated":   {group("c", "count(up)")},
+		"removed":   {group("e", "sum(up)")},
+	}
+
+	changes := computeNamespaceChanges(desired, current, func(ns string) bool { return ns != "ignored" }, rules.CompareOptions{})
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Namespace+"="+c.State.String())
+	}
+	assert.Equal(t, []string{"new=created", "removed=deleted", "unchanged=unchanged", "updated=updated"}, got)
+	assert.Len(t, current, 3, "the current rule set must not be modified")
+}
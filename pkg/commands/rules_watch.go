@@ -0,0 +1,388 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/grafana/cortex-tools/pkg/client"
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// watchDebounce is the delay between a change of the rule files and the sync,
+// so a burst of changes, like a checkout of the rule files, is synced once.
+const watchDebounce = time.Second
+
+// syncMetrics are the metrics of the syncs of the watch mode.
+type syncMetrics struct {
+	drift    *prometheus.CounterVec
+	applied  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newSyncMetrics(reg prometheus.Registerer) *syncMetrics {
+	return &syncMetrics{
+		drift: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "rules_sync_drift_detected_total",
+			Help:      "The total number of rule groups found out of sync with the rule files.",
+		}, []string{"namespace"}),
+		applied: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "rules_sync_groups_applied_total",
+			Help:      "The total number of rule group changes applied.",
+		}, []string{"namespace", "change"}),
+		failures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "rules_sync_failures_total",
+			Help:      "The total number of rule group changes that failed. Syncs failing before any change is applied are counted with an empty namespace.",
+		}, []string{"namespace"}),
+		duration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cortex",
+			Name:      "rules_sync_duration_seconds",
+			Help:      "Time taken to apply the changes of a namespace.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"namespace"}),
+	}
+}
+
+// ruleWatcher syncs the rule files of a tenant every time they change and on a
+// periodic interval, correcting the drift of the rules of the tenant.
+type ruleWatcher struct {
+	cli tenantRuleClient
+	// files returns the rule files to sync, so files added to the rule
+	// directories are picked up.
+	files        func() ([]string, error)
+	backend      string
+	parseOptions rules.ParseOptions
+	shouldCheck  func(namespace string) bool
+	compare      rules.CompareOptions
+	guards       syncGuards
+
+	concurrency     int
+	continueOnError bool
+	dryRun          bool
+
+	metrics *syncMetrics
+	// ready is set once the rules were synced for the first time.
+	ready atomic.Bool
+}
+
+// sync syncs the rule files once. Namespaces are applied one after the other,
+// so the duration of each of them can be measured.
+func (w *ruleWatcher) sync(ctx context.Context) error {
+	nss, current, err := w.load(ctx)
+	if err != nil {
+		w.metrics.failures.WithLabelValues("").Inc()
+		return err
+	}
+
+	changes := computeNamespaceChanges(nss, current, w.shouldCheck, w.compare)
+	if err := w.guards.check(nss, current, changes, w.shouldCheck); err != nil {
+		w.metrics.failures.WithLabelValues("").Inc()
+		return errors.Wrap(err, "sync aborted")
+	}
+
+	var drifted int
+	for _, change := range changes {
+		if n := len(change.GroupsCreated) + len(change.GroupsUpdated) + len(change.GroupsDeleted); n > 0 {
+			w.metrics.drift.WithLabelValues(change.Namespace).Add(float64(n))
+			drifted += n
+		}
+	}
+
+	if drifted == 0 {
+		log.Debugln("rules are in sync")
+		w.synced()
+		return nil
+	}
+	if w.dryRun {
+		log.WithField("groups", drifted).Infoln("dry run, drift detected but no changes applied")
+		w.ready.Store(true)
+		return nil
+	}
+
+	var failed int
+	for _, change := range changes {
+		if change.State == rules.Unchanged {
+			continue
+		}
+
+		start := time.Now()
+		results, err := applyChanges(ctx, w.cli, []rules.NamespaceChange{change}, w.concurrency, w.continueOnError)
+		w.metrics.duration.WithLabelValues(change.Namespace).Observe(time.Since(start).Seconds())
+
+		for _, res := range results {
+			switch res.Status {
+			case rules.GroupResultSucceeded:
+				w.metrics.applied.WithLabelValues(res.Namespace, res.Change.String()).Inc()
+			case rules.GroupResultFailed:
+				w.metrics.failures.WithLabelValues(res.Namespace).Inc()
+			}
+		}
+
+		if err != nil {
+			if !w.continueOnError {
+				return errors.Wrapf(err, "unable to sync namespace %s", change.Namespace)
+			}
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("unable to sync %d namespaces", failed)
+	}
+
+	log.WithField("groups", drifted).Infoln("rules synced")
+	w.synced()
+	return nil
+}
+
+// load parses the rule files and reads the current rules of the tenant.
+func (w *ruleWatcher) load(ctx context.Context) (map[string]rules.RuleNamespace, map[string][]rwrulefmt.RuleGroup, error) {
+	files, err := w.files()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to load rules files")
+	}
+
+	nss, err := rules.ParseFilesWithOptions(w.backend, files, w.parseOptions)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to parse rules files")
+	}
+	ruleLoadTimestamp.SetToCurrentTime()
+
+	current, err := w.cli.ListRules(ctx, "")
+	if err != nil && err != client.ErrResourceNotFound {
+		return nil, nil, errors.Wrap(err, "unable to contact cortex api")
+	}
+	return nss, current, nil
+}
+
+func (w *ruleWatcher) synced() {
+	ruleLoadSuccessTimestamp.SetToCurrentTime()
+	w.ready.Store(true)
+}
+
+// run syncs the rules, then again every time changed receives and on every
+// interval, until the context is done.
+func (w *ruleWatcher) run(ctx context.Context, interval time.Duration, changed <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.sync(ctx); err != nil && ctx.Err() == nil {
+			log.WithError(err).Errorln("unable to sync rules")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Debugln("periodic sync")
+		case <-changed:
+			log.Debugln("rule files changed")
+		}
+	}
+}
+
+// handler serves the metrics and the readiness of the watcher.
+func (w *ruleWatcher) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/ready", func(rw http.ResponseWriter, _ *http.Request) {
+		if !w.ready.Load() {
+			http.Error(rw, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		http.Error(rw, "ready", http.StatusOK)
+	})
+	return mux
+}
+
+// watchRules syncs the rule files until the process is interrupted, serving
+// the metrics of the syncs.
+func (r *RuleCommand) watchRules(ctx context.Context) error {
+	if r.WatchInterval <= 0 {
+		return errors.New("--watch-interval must be positive")
+	}
+	// There is no default, the ports commonly used next to the ruler, such as
+	// the one of Prometheus, would conflict.
+	if r.WatchListenAddress == "" {
+		return errors.New("required flag --watch-listen-address not provided with --watch")
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "unable to watch rules files")
+	}
+	defer fsw.Close()
+
+	// Directories are watched rather than files, so files replaced by editors
+	// or Kubernetes volume updates keep being watched.
+	for _, dir := range r.ruleFileDirs() {
+		if err := fsw.Add(dir); err != nil {
+			return errors.Wrapf(err, "unable to watch %s", dir)
+		}
+	}
+	for _, dir := range r.ruleDirs() {
+		if err := addWatchDir(fsw, dir); err != nil {
+			return errors.Wrapf(err, "unable to watch %s", dir)
+		}
+	}
+
+	w := &ruleWatcher{
+		cli:          r.cli,
+		files:        r.watchedRuleFiles,
+		backend:      r.Backend,
+		parseOptions: r.parseOptions(),
+		shouldCheck:  r.shouldCheckNamespace,
+		compare:      r.compareOptions(),
+		guards: syncGuards{
+			maxDeletions:        r.MaxDeletions,
+			maxDeletionsPercent: r.MaxDeletionsPercent,
+			allowEmpty:          r.AllowEmpty,
+		},
+		concurrency:     r.SyncConcurrency,
+		continueOnError: r.ContinueOnError,
+		dryRun:          r.DryRun,
+		metrics:         newSyncMetrics(prometheus.DefaultRegisterer),
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:    r.WatchListenAddress,
+		Handler: w.handler(),
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
+
+	changed := make(chan struct{}, 1)
+	go watchRuleFiles(ctx, fsw, changed)
+
+	log.WithFields(log.Fields{
+		"address":  r.WatchListenAddress,
+		"interval": r.WatchInterval,
+	}).Infoln("watching rule files")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(ctx, r.WatchInterval, changed)
+	}()
+
+	select {
+	case err := <-serveErr:
+		stop()
+		<-done
+		return errors.Wrap(err, "unable to serve metrics")
+	case <-done:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Warnln("error shutting down http server")
+	}
+	return nil
+}
+
+// watchedRuleFiles returns the rule files to sync, finding the current files of
+// the rule directories.
+func (r *RuleCommand) watchedRuleFiles() ([]string, error) {
+	files := append([]string{}, r.staticRuleFiles...)
+	for _, dir := range r.ruleDirs() {
+		found, err := findRuleFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// ruleFileDirs returns the directories of the rule files that are not found in
+// the rule directories.
+func (r *RuleCommand) ruleFileDirs() []string {
+	seen := map[string]struct{}{}
+	var dirs []string
+	for _, file := range r.staticRuleFiles {
+		dir := filepath.Dir(file)
+		if _, ok := seen[dir]; !ok {
+			seen[dir] = struct{}{}
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// addWatchDir watches a directory and all its subdirectories.
+func addWatchDir(fsw *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return fsw.Add(path)
+	})
+}
+
+// watchRuleFiles notifies changed once the watched directories stop changing
+// for watchDebounce, until the context is done. Created directories are
+// watched as well.
+func watchRuleFiles(ctx context.Context, fsw *fsnotify.Watcher, changed chan<- struct{}) {
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			log.WithFields(log.Fields{"file": event.Name, "op": event.Op}).Debugln("rule files event")
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatchDir(fsw, event.Name); err != nil {
+						log.WithError(err).WithField("dir", event.Name).Warnln("unable to watch directory")
+					}
+				}
+			}
+			debounce = time.After(watchDebounce)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+			log.WithError(err).Warnln("error watching rule files")
+		case <-debounce:
+			debounce = nil
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
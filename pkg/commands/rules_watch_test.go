@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/cortex-tools/pkg/rules"
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestRuleWatcherSync(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rules.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`namespace: ns
+groups:
+  - name: a
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+  - name: b
+    rules:
+      - record: job:up:count
+        expr: count by (job) (up)
+`), 0644))
+
+	group := func(name string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name}}
+	}
+	cli := &fakeTenantRuleClient{
+		current: map[string][]rwrulefmt.RuleGroup{"old": {group("old")}},
+		fakeRuleGroupWriter: fakeRuleGroupWriter{failing: map[string]error{
+			"ns/b": errors.New("boom"),
+		}},
+	}
+
+	reg := prometheus.NewRegistry()
+	w := &ruleWatcher{
+		cli:             cli,
+		files:           func() ([]string, error) { return []string{file}, nil },
+		backend:         rules.CortexBackend,
+		shouldCheck:     func(string) bool { return true },
+		concurrency:     1,
+		continueOnError: true,
+		metrics:         newSyncMetrics(reg),
+	}
+
+	require.EqualError(t, w.sync(context.Background()), "unable to sync 1 namespaces")
+	require.ElementsMatch(t, []string{"create ns/a", "delete old/old"}, cli.applied)
+	require.False(t, w.ready.Load())
+
+	require.Equal(t, 2.0, testutil.ToFloat64(w.metrics.drift.WithLabelValues("ns")))
+	require.Equal(t, 1.0, testutil.ToFloat64(w.metrics.drift.WithLabelValues("old")))
+	require.Equal(t, 1.0, testutil.ToFloat64(w.metrics.applied.WithLabelValues("ns", "created")))
+	require.Equal(t, 1.0, testutil.ToFloat64(w.metrics.applied.WithLabelValues("old", "deleted")))
+	require.Equal(t, 1.0, testutil.ToFloat64(w.metrics.failures.WithLabelValues("ns")))
+	require.Equal(t, 2, testutil.CollectAndCount(w.metrics.duration))
+
+	// Once the failing group can be applied, the watcher is ready.
+	cli.applied = nil
+	cli.failing = nil
+	cli.current = map[string][]rwrulefmt.RuleGroup{"ns": nil}
+	require.NoError(t, w.sync(context.Background()))
+	require.ElementsMatch(t, []string{"create ns/a", "create ns/b"}, cli.applied)
+	require.True(t, w.ready.Load())
+
+	// Syncs failing before applying any change are counted without namespace.
+	cli.listErr = errors.New("unreachable")
+	require.EqualError(t, w.sync(context.Background()), "unable to contact cortex api: unreachable")
+	require.Equal(t, 1.0, testutil.ToFloat64(w.metrics.failures.WithLabelValues("")))
+}
+
+func TestRuleWatcherSync_DryRun(t *testing.T) {
+	cli := &fakeTenantRuleClient{current: map[string][]rwrulefmt.RuleGroup{
+		"old": {{RuleGroup: rulefmt.RuleGroup{Name: "old"}}},
+	}}
+
+	w := &ruleWatcher{
+		cli:         cli,
+		files:       func() ([]string, error) { return nil, nil },
+		backend:     rules.CortexBackend,
+		shouldCheck: func(string) bool { return true },
+		guards:      syncGuards{allowEmpty: true},
+		dryRun:      true,
+		metrics:     newSyncMetrics(prometheus.NewRegistry()),
+	}
+
+	require.NoError(t, w.sync(context.Background()))
+	require.Empty(t, cli.applied)
+	require.Equal(t, 1.0, testutil.ToFloat64(w.metrics.drift.WithLabelValues("old")))
+	require.True(t, w.ready.Load())
+}
+
+func TestRuleWatcherReady(t *testing.T) {
+	w := &ruleWatcher{}
+	handler := w.handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	w.ready.Store(true)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestWatchRuleFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	fsw, err := fsnotify.NewWatcher()
+	require.NoError(t, err)
+	defer fsw.Close()
+	require.NoError(t, addWatchDir(fsw, dir))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	go watchRuleFiles(ctx, fsw, changed)
+
+	// Files of created directories are watched too.
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("directory creation not notified")
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "rules.yaml"), []byte("groups: []\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "rules.yaml"), []byte("groups: []\n"), 0644))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rule file change not notified")
+	}
+
+	// Both writes are debounced into a single notification.
+	select {
+	case <-changed:
+		t.Fatal("changes notified twice")
+	case <-time.After(2 * watchDebounce):
+	}
+}
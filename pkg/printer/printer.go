@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/alecthomas/chroma/quick"
 	"github.com/mitchellh/colorstring"
@@ -150,19 +151,9 @@ func (p *Printer) PrintComparisonResult(results []rules.NamespaceChange, verbose
 			for _, c := range change.GroupsUpdated {
 				p.Printf("[yellow]  ~ Group: %v\n", c.New.Name)
 
-				// Print the full diff of the rules if verbose is set
+				// Print the diff of every changed field and rule if verbose is set
 				if verbose {
-					newYaml, _ := yaml.Marshal(c.New)
-					separated := strings.Split(string(newYaml), "\n")
-					for _, l := range separated {
-						p.Printf("[green]+ %v\n", l)
-					}
-
-					oldYaml, _ := yaml.Marshal(c.Original)
-					separated = strings.Split(string(oldYaml), "\n")
-					for _, l := range separated {
-						p.Printf("[red]- %v\n", l)
-					}
+					p.printGroupDiff(c.Diff())
 				}
 			}
 
@@ -182,6 +173,244 @@ func (p *Printer) PrintComparisonResult(results []rules.NamespaceChange, verbose
 	return nil
 }
 
+// PrintChangeReport prints the machine readable report of a set of rule
+// namespace changes in the given format (json or yaml). The output is never
+// colored so it can be consumed by other programs.
+func (p *Printer) PrintChangeReport(report rules.ChangeReport, format string, writer io.Writer) error {
+	return printReport(report, format, writer)
+}
+
+// PrintMultiTenantReport prints the report of the changes of several tenants
+// in the given machine readable format (json or yaml).
+func (p *Printer) PrintMultiTenantReport(report rules.MultiTenantReport, format string, writer io.Writer) error {
+	return printReport(report, format, writer)
+}
+
+// PrintTenantSummary prints the number of rule groups created, updated,
+// deleted and failed for every tenant as a table.
+func (p *Printer) PrintTenantSummary(reports []rules.TenantReport, writer io.Writer) error {
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Tenant\t Created\t Updated\t Deleted\t Failed\t Status")
+	for _, r := range reports {
+		status := "unchanged"
+		switch {
+		case r.Error != "":
+			status = "error: " + r.Error
+		case r.Summary.HasChanges:
+			status = "changed"
+		}
+		fmt.Fprintf(w, "%s\t %d\t %d\t %d\t %d\t %s\n", r.Tenant, r.Summary.GroupsCreated, r.Summary.GroupsUpdated, r.Summary.GroupsDeleted, r.Summary.GroupsFailed, status)
+	}
+	return w.Flush()
+}
+
+// PrintBacktestResults prints the backtest result of every alerting rule as a
+// table, or in the given machine readable format (json or yaml).
+func (p *Printer) PrintBacktestResults(results []rules.BacktestResult, format string, writer io.Writer) error {
+	if format == "json" || format == "yaml" {
+		if results == nil {
+			results = []rules.BacktestResult{}
+		}
+		return printReport(results, format, writer)
+	}
+
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Namespace\t Rule Group\t Alert\t Series\t Firings\t Firing Time\t Flaps")
+	for _, res := range results {
+		if res.Error != "" {
+			fmt.Fprintf(w, "%s\t %s\t %s\t error: %s\t\t\t\n", res.Namespace, res.Group, res.Alert, res.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t %s\t %s\t %d\t %d\t %v\t %d\n", res.Namespace, res.Group, res.Alert, res.Series, res.Firings, res.FiringTime, res.Flaps)
+	}
+
+	return w.Flush()
+}
+
+// PrintCostResults prints the estimated cost of rule groups, followed by the
+// suggested split of the groups at risk of missing evaluations.
+func (p *Printer) PrintCostResults(results []rules.GroupCost, format string, writer io.Writer) error {
+	if format == "json" || format == "yaml" {
+		if results == nil {
+			results = []rules.GroupCost{}
+		}
+		return printReport(results, format, writer)
+	}
+
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Namespace\t Rule Group\t Rules\t Series\t Latency\t Interval\t Utilization\t Status")
+	for _, res := range results {
+		status := "ok"
+		if res.AtRisk {
+			status = "at risk"
+		}
+		for _, r := range res.Rules {
+			if r.Error != "" {
+				status += ", errors"
+				break
+			}
+		}
+		fmt.Fprintf(w, "%s\t %s\t %d\t %d\t %v\t %v\t %.0f%%\t %s\n", res.Namespace, res.Group, len(res.Rules), res.Series, time.Duration(res.Latency).Round(time.Millisecond), res.Interval, res.Utilization*100, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		if len(res.SuggestedSplit) == 0 {
+			continue
+		}
+		fmt.Fprintf(writer, "\n%s/%s could be split into %d groups:\n", res.Namespace, res.Group, len(res.SuggestedSplit))
+		for i, group := range res.SuggestedSplit {
+			fmt.Fprintf(writer, "  %d: %s\n", i+1, strings.Join(group, ", "))
+		}
+	}
+
+	return nil
+}
+
+// PrintDependencyGraph prints a rule dependency graph in the DOT or JSON
+// format. In the DOT format, rules are clustered by group and edges point from
+// rules to the recording rules they depend on.
+func (p *Printer) PrintDependencyGraph(graph *rules.DependencyGraph, format string, writer io.Writer) error {
+	if format != "dot" {
+		return printReport(graph, format, writer)
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph rules {\n")
+	for i := 0; i < len(graph.Nodes); {
+		group := graph.Nodes[i].Namespace + "/" + graph.Nodes[i].Group
+		fmt.Fprintf(&b, "  subgraph %q {\n", "cluster_"+group)
+		fmt.Fprintf(&b, "    label=%q;\n", group)
+		for ; i < len(graph.Nodes) && graph.Nodes[i].Namespace+"/"+graph.Nodes[i].Group == group; i++ {
+			shape := "ellipse"
+			if graph.Nodes[i].Recording {
+				shape = "box"
+			}
+			fmt.Fprintf(&b, "    %q [label=%q, shape=%s];\n", graph.Nodes[i].ID, graph.Nodes[i].Rule, shape)
+		}
+		b.WriteString("  }\n")
+	}
+	for _, e := range graph.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Metric)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(writer, b.String())
+	return err
+}
+
+// printReport prints a value in a machine readable format, without color.
+func printReport(v interface{}, format string, writer io.Writer) error {
+	var output []byte
+	var err error
+
+	switch format {
+	case "json":
+		output, err = json.MarshalIndent(v, "", "  ")
+		output = append(output, '\n')
+	case "yaml":
+		output, err = yaml.Marshal(v)
+	default:
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(output)
+	return err
+}
+
+// PrintSyncResults prints the outcome of every rule group change applied by a
+// sync, so partial failures show exactly which groups are out of sync.
+func (p *Printer) PrintSyncResults(results []rules.GroupResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	fmt.Println("Sync Results:")
+	for _, res := range results {
+		switch res.Status {
+		case rules.GroupResultSucceeded:
+			p.Printf("[green]  ✓ %v %v/%v\n", res.Change, res.Namespace, res.Group)
+		case rules.GroupResultFailed:
+			p.Printf("[red]  ✗ %v %v/%v: %v\n", res.Change, res.Namespace, res.Group, res.Error)
+		default:
+			p.Printf("[yellow]  - %v %v/%v: %v\n", res.Change, res.Namespace, res.Group, res.Status)
+		}
+	}
+}
+
+// PrintExprChanges prints the expression of every modified rule before and
+// after the change.
+func (p *Printer) PrintExprChanges(changes []rules.ExprChange) {
+	for _, c := range changes {
+		if c.RenamedFrom != "" {
+			p.Printf("[yellow]~ %v/%v/%v -> %v\n", c.Namespace, c.Group, c.RenamedFrom, c.Rule)
+		} else {
+			p.Printf("[yellow]~ %v/%v/%v\n", c.Namespace, c.Group, c.Rule)
+		}
+		if c.Before != c.After {
+			p.Printf("[red]  - %v\n", c.Before)
+			p.Printf("[green]  + %v\n", c.After)
+		}
+	}
+}
+
+// PrintRecordingRuleRenames prints a table mapping the renamed recording rules
+// to their new name.
+func (p *Printer) PrintRecordingRuleRenames(renames []rules.RecordingRuleRename, writer io.Writer) error {
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Namespace\t Rule Group\t Recording Rule\t New Name")
+	for _, r := range renames {
+		fmt.Fprintf(w, "%s\t %s\t %s\t %s\n", r.Namespace, r.Group, r.From, r.To)
+	}
+
+	return w.Flush()
+}
+
+// printGroupDiff prints the unified diff of each group-level field and rule
+// that changed within an updated rule group.
+func (p *Printer) printGroupDiff(diff rules.GroupDiff) {
+	for _, f := range diff.Fields {
+		p.Printf("[yellow]    ~ %v\n", f.Field)
+		p.printUnifiedDiff(f.Diff)
+	}
+
+	for _, r := range diff.Rules {
+		switch r.State {
+		case rules.Created:
+			p.Printf("[green]    + Rule: %v\n", r.Name)
+		case rules.Updated:
+			p.Printf("[yellow]    ~ Rule: %v\n", r.Name)
+		case rules.Deleted:
+			p.Printf("[red]    - Rule: %v\n", r.Name)
+		}
+		p.printUnifiedDiff(r.Diff)
+	}
+}
+
+func (p *Printer) printUnifiedDiff(diff string) {
+	for _, l := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			p.Printf("[cyan]      %v\n", l)
+		case strings.HasPrefix(l, "+"):
+			p.Printf("[green]      %v\n", l)
+		case strings.HasPrefix(l, "-"):
+			p.Printf("[red]      %v\n", l)
+		default:
+			p.Printf("      %v\n", l)
+		}
+	}
+}
+
 func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format string, writer io.Writer) error {
 	nsKeys := make([]string, 0, len(rules))
 	for k := range rules {
@@ -242,3 +471,63 @@ func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format st
 
 	return nil
 }
+
+// PrintGroupHealth prints the evaluation state of rule groups as a table, or
+// in the given machine readable format (json or yaml).
+func (p *Printer) PrintGroupHealth(groups []rules.GroupHealth, format string, writer io.Writer) error {
+	if format == "json" || format == "yaml" {
+		if groups == nil {
+			groups = []rules.GroupHealth{}
+		}
+		return printReport(groups, format, writer)
+	}
+
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Namespace\t Rule Group\t Health\t Firing\t Last Evaluation\t Evaluation Time\t Last Error")
+	for _, g := range groups {
+		fmt.Fprintf(w, "%s\t %s\t %s\t %d\t %s\t %v\t %s\n", g.Namespace, g.Group, g.Health, g.FiringAlerts, formatLastEvaluation(g.LastEvaluation), formatEvaluationTime(g.EvaluationSeconds), singleLine(g.LastError))
+	}
+
+	return w.Flush()
+}
+
+// PrintRuleHealth prints the evaluation state of every rule of the rule groups
+// as a table, or in the given machine readable format (json or yaml).
+func (p *Printer) PrintRuleHealth(groups []rules.GroupHealth, format string, writer io.Writer) error {
+	if format == "json" || format == "yaml" {
+		if groups == nil {
+			groups = []rules.GroupHealth{}
+		}
+		return printReport(groups, format, writer)
+	}
+
+	w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
+
+	fmt.Fprintln(w, "Namespace\t Rule Group\t Rule\t Type\t Health\t State\t Firing\t Last Evaluation\t Evaluation Time\t Last Error")
+	for _, g := range groups {
+		for _, r := range g.Rules {
+			fmt.Fprintf(w, "%s\t %s\t %s\t %s\t %s\t %s\t %d\t %s\t %v\t %s\n", g.Namespace, g.Group, r.Rule, r.Type, r.Health, r.State, r.FiringAlerts, formatLastEvaluation(r.LastEvaluation), formatEvaluationTime(r.EvaluationSeconds), singleLine(r.LastError))
+		}
+	}
+
+	return w.Flush()
+}
+
+// formatLastEvaluation formats the time of the last evaluation of a rule, which
+// is zero if it was never evaluated.
+func formatLastEvaluation(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatEvaluationTime(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Microsecond)
+}
+
+// singleLine collapses whitespace so errors don't break the table layout.
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
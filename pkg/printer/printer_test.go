@@ -3,12 +3,15 @@ package printer
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/alecthomas/chroma/quick"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/cortex-tools/pkg/rules"
 	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
 )
 
@@ -96,3 +99,143 @@ test-namespace-2 | test-rulegroup-d
 		})
 	}
 }
+
+func TestPrintBacktestResults(t *testing.T) {
+	results := []rules.BacktestResult{
+		{Namespace: "ns", Group: "group", Alert: "Down", Series: 2, Firings: 3, FiringTime: model.Duration(90 * time.Minute), Flaps: 1},
+		{Namespace: "ns", Group: "group", Alert: "Broken", Error: "bad query"},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintBacktestResults(results, "text", &b))
+	assert.Equal(t, `Namespace | Rule Group | Alert  | Series           | Firings | Firing Time | Flaps
+ns        | group      | Down   | 2                | 3       | 1h30m       | 1
+ns        | group      | Broken | error: bad query |         |             |
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, New(true).PrintBacktestResults(nil, "json", &b))
+	assert.Equal(t, "[]\n", b.String())
+}
+
+func TestPrintDependencyGraph(t *testing.T) {
+	graph := &rules.DependencyGraph{
+		Nodes: []rules.GraphNode{
+			{ID: "ns/group/0", Namespace: "ns", Group: "group", Index: 0, Rule: "job:up:sum", Recording: true},
+			{ID: "ns/group/1", Namespace: "ns", Group: "group", Index: 1, Rule: "JobDown"},
+			{ID: "ns/other/0", Namespace: "ns", Group: "other", Index: 0, Rule: "JobMissing"},
+		},
+		Edges: []rules.GraphEdge{
+			{From: "ns/group/1", To: "ns/group/0", Metric: "job:up:sum"},
+			{From: "ns/other/0", To: "ns/group/0", Metric: "job:up:sum"},
+		},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintDependencyGraph(graph, "dot", &b))
+	assert.Equal(t, `digraph rules {
+  subgraph "cluster_ns/group" {
+    label="ns/group";
+    "ns/group/0" [label="job:up:sum", shape=box];
+    "ns/group/1" [label="JobDown", shape=ellipse];
+  }
+  subgraph "cluster_ns/other" {
+    label="ns/other";
+    "ns/other/0" [label="JobMissing", shape=ellipse];
+  }
+  "ns/group/1" -> "ns/group/0" [label="job:up:sum"];
+  "ns/other/0" -> "ns/group/0" [label="job:up:sum"];
+}
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, New(true).PrintDependencyGraph(&rules.DependencyGraph{Nodes: []rules.GraphNode{}, Edges: []rules.GraphEdge{}}, "json", &b))
+	assert.Equal(t, "{\n  \"nodes\": [],\n  \"edges\": []\n}\n", b.String())
+}
+
+func TestPrintCostResults(t *testing.T) {
+	results := []rules.GroupCost{
+		{
+			Namespace: "ns", Group: "fast", Interval: model.Duration(time.Minute), Series: 12, Latency: model.Duration(1500 * time.Millisecond), Utilization: 0.025,
+			Rules: []rules.RuleCost{{Rule: "a"}, {Rule: "b", Error: "bad query"}},
+		},
+		{
+			Namespace: "ns", Group: "slow", Interval: model.Duration(time.Minute), Series: 50000, Latency: model.Duration(90 * time.Second), Utilization: 1.5, AtRisk: true,
+			Rules:          []rules.RuleCost{{Rule: "c"}, {Rule: "d"}, {Rule: "e"}},
+			SuggestedSplit: [][]string{{"c", "e"}, {"d"}},
+		},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintCostResults(results, "text", &b))
+	assert.Equal(t, `Namespace | Rule Group | Rules | Series | Latency | Interval | Utilization | Status
+ns        | fast       | 2     | 12     | 1.5s    | 1m       | 2%          | ok, errors
+ns        | slow       | 3     | 50000  | 1m30s   | 1m       | 150%        | at risk
+
+ns/slow could be split into 2 groups:
+  1: c, e
+  2: d
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, New(true).PrintCostResults(nil, "json", &b))
+	assert.Equal(t, "[]\n", b.String())
+}
+
+func TestPrintTenantSummary(t *testing.T) {
+	reports := []rules.TenantReport{
+		{Tenant: "team-a", ChangeReport: rules.ChangeReport{Summary: rules.ChangeSummary{HasChanges: true, GroupsCreated: 2, GroupsFailed: 1}}},
+		{Tenant: "team-b"},
+		{Tenant: "team-c", Error: "unreachable"},
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintTenantSummary(reports, &b))
+	assert.Equal(t, `Tenant | Created | Updated | Deleted | Failed | Status
+team-a | 2       | 0       | 0       | 1      | changed
+team-b | 0       | 0       | 0       | 0      | unchanged
+team-c | 0       | 0       | 0       | 0      | error: unreachable
+`, b.String())
+}
+
+func TestPrintRuleHealth(t *testing.T) {
+	evaluated := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	groups := []rules.GroupHealth{
+		rules.NewGroupHealth("ns", "alerts", evaluated, 0.0025, []rules.RuleHealth{
+			{Rule: "HighErrorRate", Type: "alerting", Health: rules.HealthOK, State: "firing", LastEvaluation: evaluated, EvaluationSeconds: 0.0015, FiringAlerts: 2},
+			{Rule: "Down", Type: "alerting", Health: rules.HealthError, State: "inactive", LastError: "query timed out\nafter 2m", LastEvaluation: evaluated, EvaluationSeconds: 0.001},
+		}),
+		rules.NewGroupHealth("ns", "recordings", time.Time{}, 0, []rules.RuleHealth{
+			{Rule: "job:up:sum", Type: "recording", Health: rules.HealthUnknown},
+		}),
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintGroupHealth(groups, "table", &b))
+	assert.Equal(t, `Namespace | Rule Group | Health  | Firing | Last Evaluation      | Evaluation Time | Last Error
+ns        | alerts     | err     | 2      | 2023-01-02T03:04:05Z | 2.5ms           | query timed out after 2m
+ns        | recordings | unknown | 0      | never                | 0s              | 
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, New(true).PrintRuleHealth(groups, "table", &b))
+	assert.Equal(t, `Namespace | Rule Group | Rule          | Type      | Health  | State    | Firing | Last Evaluation      | Evaluation Time | Last Error
+ns        | alerts     | HighErrorRate | alerting  | ok      | firing   | 2      | 2023-01-02T03:04:05Z | 1.5ms           | 
+ns        | alerts     | Down          | alerting  | err     | inactive | 0      | 2023-01-02T03:04:05Z | 1ms             | query timed out after 2m
+ns        | recordings | job:up:sum    | recording | unknown |          | 0      | never                | 0s              | 
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, New(true).PrintRuleHealth(nil, "json", &b))
+	assert.Equal(t, "[]\n", b.String())
+}
+
+func TestPrintRecordingRuleRenames(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintRecordingRuleRenames([]rules.RecordingRuleRename{
+		{Namespace: "ns", Group: "requests", From: "requests_rate", To: "job:http_requests:rate5m"},
+	}, &b))
+	assert.Equal(t, `Namespace | Rule Group | Recording Rule | New Name
+ns        | requests   | requests_rate  | job:http_requests:rate5m
+`, b.String())
+}
@@ -0,0 +1,269 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// Names of the expression checks, as reported in findings.
+const (
+	RateOverGaugeCheck       = "rate-over-gauge"
+	RateRangeCheck           = "rate-range"
+	AbsentWithoutLabelsCheck = "absent-without-labels"
+	VectorMatchingCheck      = "vector-matching"
+	UnanchoredRegexCheck     = "unanchored-regex"
+)
+
+// gaugeMetricType is the type of gauges in the metric metadata.
+const gaugeMetricType = "gauge"
+
+// counterSuffixes are the suffixes of the metric names that are counters by
+// convention. They are used to detect gauges when the metric metadata is not
+// known.
+var counterSuffixes = []string{"_total", "_count", "_sum", "_bucket"}
+
+// ExprAnalyzer looks for common semantic mistakes in the PromQL expressions of
+// rules.
+type ExprAnalyzer struct {
+	// MetricTypes maps metric names to their type (counter, gauge, histogram...)
+	// as returned by the metadata API. If nil, counters are detected by the
+	// suffix of their name.
+	MetricTypes map[string]string
+	// DefaultInterval is the evaluation interval of the groups that don't set one.
+	DefaultInterval time.Duration
+}
+
+// Analyze returns the findings of every rule expression of the namespace, in
+// the order of the groups and rules. Expressions that can't be parsed are
+// skipped, they are reported by the parser.
+func (a ExprAnalyzer) Analyze(ns RuleNamespace) []Finding {
+	var findings []Finding
+
+	for _, g := range ns.Groups {
+		interval := time.Duration(g.Interval)
+		if interval == 0 {
+			interval = a.DefaultInterval
+		}
+
+		for _, r := range g.Rules {
+			expr, err := parser.ParseExpr(r.Expr.Value)
+			if err != nil {
+				continue
+			}
+
+			for _, w := range a.AnalyzeExpr(expr, interval) {
+				w.File = ns.Filepath
+				w.Namespace = ns.Namespace
+				w.Group = g.Name
+				w.Rule = getRuleName(r)
+				findings = append(findings, w)
+			}
+		}
+	}
+
+	return findings
+}
+
+// AnalyzeExpr returns the findings of a single expression evaluated every
+// interval. Only the Check and Message of the findings are set.
+func (a ExprAnalyzer) AnalyzeExpr(expr parser.Expr, interval time.Duration) []Finding {
+	var findings []Finding
+	report := func(check, format string, args ...interface{}) {
+		findings = append(findings, Finding{Check: check, Message: fmt.Sprintf(format, args...)})
+	}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.Call:
+			a.analyzeCall(n, interval, report)
+		case *parser.BinaryExpr:
+			analyzeBinaryExpr(n, report)
+		case *parser.VectorSelector:
+			for _, m := range n.LabelMatchers {
+				if (m.Type == labels.MatchRegexp || m.Type == labels.MatchNotRegexp) && !hasAnchorableLiteral(m.Value) {
+					report(UnanchoredRegexCheck, "regex matcher %s has no literal prefix, it has to be run against every value of the label", m)
+				}
+			}
+		}
+		return nil
+	})
+
+	return findings
+}
+
+func (a ExprAnalyzer) analyzeCall(n *parser.Call, interval time.Duration, report func(check, format string, args ...interface{})) {
+	switch n.Func.Name {
+	case "rate", "irate", "increase":
+		ms, ok := unwrapExpr(n.Args[0]).(*parser.MatrixSelector)
+		if !ok {
+			return
+		}
+
+		if interval > 0 && ms.Range < 2*interval {
+			report(RateRangeCheck, "%s() range [%s] is shorter than twice the evaluation interval of %s", n.Func.Name, model.Duration(ms.Range), model.Duration(interval))
+		}
+
+		vs, ok := ms.VectorSelector.(*parser.VectorSelector)
+		if !ok || vs.Name == "" {
+			return
+		}
+		if a.MetricTypes != nil {
+			if a.metricType(vs.Name) == gaugeMetricType {
+				report(RateOverGaugeCheck, "%s() is applied to %s which is a gauge", n.Func.Name, vs.Name)
+			}
+			return
+		}
+		// Recording rules usually don't follow the counter naming conventions.
+		if !strings.Contains(vs.Name, ":") && !hasCounterSuffix(vs.Name) {
+			report(RateOverGaugeCheck, "%s() is applied to %s which does not have a counter suffix, it might be a gauge", n.Func.Name, vs.Name)
+		}
+
+	case "absent", "absent_over_time":
+		var vs *parser.VectorSelector
+		switch arg := unwrapExpr(n.Args[0]).(type) {
+		case *parser.VectorSelector:
+			vs = arg
+		case *parser.MatrixSelector:
+			vs, _ = arg.VectorSelector.(*parser.VectorSelector)
+		}
+		if vs == nil {
+			return
+		}
+
+		for _, m := range vs.LabelMatchers {
+			if m.Name != model.MetricNameLabel {
+				return
+			}
+		}
+		report(AbsentWithoutLabelsCheck, "%s() of %s has no label matcher, the resulting series has no labels to identify what is missing", n.Func.Name, vs)
+	}
+}
+
+// metricType returns the type of a metric. The series of histograms and
+// summaries are looked up by the name of their metric family.
+func (a ExprAnalyzer) metricType(name string) string {
+	if t, ok := a.MetricTypes[name]; ok {
+		return t
+	}
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if t, ok := a.MetricTypes[strings.TrimSuffix(name, suffix)]; ok && strings.HasSuffix(name, suffix) {
+			return t
+		}
+	}
+	return ""
+}
+
+func analyzeBinaryExpr(n *parser.BinaryExpr, report func(check, format string, args ...interface{})) {
+	if n.Op.IsSetOperator() || n.LHS.Type() != parser.ValueTypeVector || n.RHS.Type() != parser.ValueTypeVector {
+		return
+	}
+	if n.VectorMatching != nil && (n.VectorMatching.On || len(n.VectorMatching.MatchingLabels) > 0) {
+		return
+	}
+
+	lhs, lhsKnown := groupingLabels(n.LHS)
+	rhs, rhsKnown := groupingLabels(n.RHS)
+	switch {
+	case lhsKnown && rhsKnown:
+		if strings.Join(lhs, ",") != strings.Join(rhs, ",") {
+			report(VectorMatchingCheck, "the operands of %s are aggregated by different labels (%s) and (%s) without on() or ignoring()", n.Op, strings.Join(lhs, ", "), strings.Join(rhs, ", "))
+		}
+	case lhsKnown && isSelector(n.RHS), rhsKnown && isSelector(n.LHS):
+		report(VectorMatchingCheck, "only one operand of %s is aggregated, the other keeps all of its labels and no on() or ignoring() is set", n.Op)
+	}
+}
+
+// groupingLabels returns the sorted labels an expression is aggregated by,
+// known is false if the labels of the result can't be known from the
+// expression alone.
+func groupingLabels(expr parser.Expr) (result []string, known bool) {
+	agg, ok := unwrapExpr(expr).(*parser.AggregateExpr)
+	if !ok || agg.Without {
+		return nil, false
+	}
+
+	switch agg.Op {
+	case parser.TOPK, parser.BOTTOMK, parser.COUNT_VALUES:
+		return nil, false
+	}
+
+	result = append(result, agg.Grouping...)
+	sort.Strings(result)
+	return result, true
+}
+
+func isSelector(expr parser.Expr) bool {
+	_, ok := unwrapExpr(expr).(*parser.VectorSelector)
+	return ok
+}
+
+// unwrapExpr returns the expression within parentheses and step invariant
+// wrappers.
+func unwrapExpr(expr parser.Expr) parser.Expr {
+	for {
+		switch e := expr.(type) {
+		case *parser.ParenExpr:
+			expr = e.Expr
+		case *parser.StepInvariantExpr:
+			expr = e.Expr
+		default:
+			return expr
+		}
+	}
+}
+
+func hasCounterSuffix(name string) bool {
+	for _, suffix := range counterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnchorableLiteral returns whether a regex matcher value has a literal
+// prefix or only matches a set of literal strings, so it doesn't need to be
+// matched against every value of the label.
+func hasAnchorableLiteral(value string) bool {
+	re, err := regexp.Compile("^(?:" + value + ")$")
+	if err != nil {
+		return true
+	}
+	if prefix, _ := re.LiteralPrefix(); prefix != "" {
+		return true
+	}
+
+	parsed, err := syntax.Parse(value, syntax.Perl)
+	if err != nil {
+		return true
+	}
+	return isLiteralSet(parsed.Simplify())
+}
+
+func isLiteralSet(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral, syntax.OpEmptyMatch:
+		return re.Flags&syntax.FoldCase == 0
+	case syntax.OpCharClass:
+		return true
+	case syntax.OpCapture:
+		return isLiteralSet(re.Sub[0])
+	case syntax.OpAlternate, syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !isLiteralSet(sub) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
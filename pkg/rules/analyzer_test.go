@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestExprAnalyzer_AnalyzeExpr(t *testing.T) {
+	metricTypes := map[string]string{
+		"http_requests_total":      "counter",
+		"memory_bytes":             "gauge",
+		"request_duration_seconds": "histogram",
+	}
+
+	tests := []struct {
+		name        string
+		expr        string
+		metricTypes map[string]string
+		expected    []Finding
+	}{
+		{
+			name: "valid expression",
+			expr: `sum by (job) (rate(http_requests_total{job=~"api|web"}[5m])) / sum by (job) (rate(http_requests_total{job=~"api.*"}[5m]))`,
+		},
+		{
+			name: "rate over a gauge from the metadata",
+			expr: `rate(memory_bytes[5m])`, metricTypes: metricTypes,
+			expected: []Finding{{Check: RateOverGaugeCheck, Message: "rate() is applied to memory_bytes which is a gauge"}},
+		},
+		{
+			name: "rate over a histogram bucket from the metadata",
+			expr: `rate(request_duration_seconds_bucket[5m])`, metricTypes: metricTypes,
+		},
+		{
+			name:     "rate over a metric without counter suffix",
+			expr:     `increase(memory_bytes[5m])`,
+			expected: []Finding{{Check: RateOverGaugeCheck, Message: "increase() is applied to memory_bytes which does not have a counter suffix, it might be a gauge"}},
+		},
+		{
+			name: "rate over a recording rule",
+			expr: `rate(job:http_requests:sum[5m])`,
+		},
+		{
+			name:     "rate range too short",
+			expr:     `rate(http_requests_total[1m])`,
+			expected: []Finding{{Check: RateRangeCheck, Message: "rate() range [1m] is shorter than twice the evaluation interval of 1m"}},
+		},
+		{
+			name:     "absent without labels",
+			expr:     `absent(up)`,
+			expected: []Finding{{Check: AbsentWithoutLabelsCheck, Message: "absent() of up has no label matcher, the resulting series has no labels to identify what is missing"}},
+		},
+		{
+			name: "absent with labels",
+			expr: `absent_over_time(up{job="api"}[5m])`,
+		},
+		{
+			name:     "ratio of different aggregations",
+			expr:     `sum by (job) (http_requests_total) / sum by (instance) (http_requests_total)`,
+			expected: []Finding{{Check: VectorMatchingCheck, Message: "the operands of / are aggregated by different labels (job) and (instance) without on() or ignoring()"}},
+		},
+		{
+			name:     "ratio of an aggregation and a selector",
+			expr:     `sum by (job) (http_requests_total) / up`,
+			expected: []Finding{{Check: VectorMatchingCheck, Message: "only one operand of / is aggregated, the other keeps all of its labels and no on() or ignoring() is set"}},
+		},
+		{
+			name: "ratio with explicit matching",
+			expr: `sum by (job) (http_requests_total) / on (job) group_left up`,
+		},
+		{
+			name: "regex without literal",
+			expr: `up{job=~".*api", instance!~".+"}`,
+			expected: []Finding{
+				{Check: UnanchoredRegexCheck, Message: `regex matcher job=~".*api" has no literal prefix, it has to be run against every value of the label`},
+				{Check: UnanchoredRegexCheck, Message: `regex matcher instance!~".+" has no literal prefix, it has to be run against every value of the label`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			require.NoError(t, err)
+
+			a := ExprAnalyzer{MetricTypes: tt.metricTypes}
+			require.Equal(t, tt.expected, a.AnalyzeExpr(expr, time.Minute))
+		})
+	}
+}
+
+func TestExprAnalyzer_Analyze(t *testing.T) {
+	ns := RuleNamespace{
+		Namespace: "ns",
+		Filepath:  "rules.yaml",
+		Groups: []rwrulefmt.RuleGroup{{
+			RuleGroup: rulefmt.RuleGroup{
+				Name:     "group",
+				Interval: model.Duration(5 * time.Minute),
+				Rules: []rulefmt.RuleNode{
+					{Record: yaml.Node{Value: "job:http_requests:rate5m"}, Expr: yaml.Node{Value: "sum by (job) (rate(http_requests_total[5m]))"}},
+				},
+			},
+		}},
+	}
+
+	require.Equal(t, []Finding{{
+		File:      "rules.yaml",
+		Namespace: "ns",
+		Group:     "group",
+		Rule:      "job:http_requests:rate5m",
+		Check:     RateRangeCheck,
+		Message:   "rate() range [5m] is shorter than twice the evaluation interval of 5m",
+	}}, ExprAnalyzer{DefaultInterval: time.Minute}.Analyze(ns))
+}
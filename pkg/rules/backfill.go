@@ -0,0 +1,159 @@
+package rules
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// EvaluateRecordingRules evaluates every recording rule of the namespaces
+// between start and end with range queries, using the evaluation interval of
+// its group as step. It returns the series the rules would have recorded:
+// the metric name is set to the name of the rule and the rule labels are
+// applied, like the Prometheus rule manager does.
+//
+// Rules are evaluated independently, so a rule that uses the output of
+// another recording rule being evaluated only sees the data already stored
+// for it. A warning is logged for every such rule.
+//
+// The series of rules recording the same labels, for instance the same
+// metric in several groups, are merged into a single series ordered by
+// timestamp. When several rules record a sample at the same timestamp, the
+// sample of the first rule is kept and a warning is logged.
+func EvaluateRecordingRules(ctx context.Context, q RangeQuerier, namespaces map[string]RuleNamespace, start, end time.Time, defaultInterval time.Duration) (model.Matrix, error) {
+	names := make([]string, 0, len(namespaces))
+	recorded := map[string]struct{}{}
+	for name, ns := range namespaces {
+		names = append(names, name)
+		for _, g := range ns.Groups {
+			for _, r := range g.Rules {
+				if r.Record.Value != "" {
+					recorded[r.Record.Value] = struct{}{}
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var result model.Matrix
+	series := map[model.Fingerprint]*model.SampleStream{}
+	for _, name := range names {
+		for _, g := range namespaces[name].Groups {
+			step := time.Duration(g.Interval)
+			if step == 0 {
+				step = defaultInterval
+			}
+
+			for _, r := range g.Rules {
+				if r.Record.Value == "" {
+					continue
+				}
+
+				logger := log.WithFields(log.Fields{
+					"namespace": name,
+					"group":     g.Name,
+					"rule":      r.Record.Value,
+				})
+
+				if deps := recordedDependencies(r.Expr.Value, recorded); len(deps) > 0 {
+					logger.WithField("dependencies", deps).Warnln("rule depends on other recording rules, only their already stored data is used")
+				}
+
+				logger.Debugln("evaluating recording rule")
+				matrix, err := queryRange(ctx, q, r.Expr.Value, start, end, step)
+				if err != nil {
+					return nil, errors.Wrapf(err, "unable to evaluate recording rule %s in group %s of namespace %s", r.Record.Value, g.Name, name)
+				}
+
+				for _, s := range matrix {
+					s.Metric = recordedMetric(s.Metric, r.Record.Value, r.Labels)
+					if existing, ok := series[s.Metric.Fingerprint()]; ok {
+						existing.Values = append(existing.Values, s.Values...)
+						continue
+					}
+					series[s.Metric.Fingerprint()] = s
+					result = append(result, s)
+				}
+			}
+		}
+	}
+
+	for _, s := range result {
+		s.Values = uniqueSamples(s.Metric, s.Values)
+	}
+
+	return result, nil
+}
+
+// uniqueSamples sorts the samples of a series by timestamp and removes the
+// samples with the same timestamp as a previous one, keeping the first.
+func uniqueSamples(m model.Metric, values []model.SamplePair) []model.SamplePair {
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Timestamp < values[j].Timestamp
+	})
+
+	result := values[:0]
+	duplicates := 0
+	for _, v := range values {
+		if len(result) > 0 && result[len(result)-1].Timestamp == v.Timestamp {
+			duplicates++
+			continue
+		}
+		result = append(result, v)
+	}
+	if duplicates > 0 {
+		log.WithFields(log.Fields{
+			"series":     m.String(),
+			"duplicates": duplicates,
+		}).Warnln("several recording rules record the same series, only the samples of the first rule are kept")
+	}
+	return result
+}
+
+// recordedMetric returns the labels of a series recorded by a rule.
+func recordedMetric(m model.Metric, record string, ruleLabels map[string]string) model.Metric {
+	out := m.Clone()
+	out[model.MetricNameLabel] = model.LabelValue(record)
+	for name, value := range ruleLabels {
+		if value == "" {
+			delete(out, model.LabelName(name))
+			continue
+		}
+		out[model.LabelName(name)] = model.LabelValue(value)
+	}
+	return out
+}
+
+// recordedDependencies returns the sorted metric names used by the
+// expression that are recorded by one of the given rules.
+func recordedDependencies(expr string, recorded map[string]struct{}) []string {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil
+	}
+
+	deps := map[string]struct{}{}
+	for _, sel := range parser.ExtractSelectors(parsed) {
+		for _, m := range sel {
+			if m.Name != model.MetricNameLabel || m.Type != labels.MatchEqual {
+				continue
+			}
+			if _, ok := recorded[m.Value]; ok {
+				deps[m.Value] = struct{}{}
+			}
+		}
+	}
+
+	result := make([]string, 0, len(deps))
+	for d := range deps {
+		result = append(result, d)
+	}
+	sort.Strings(result)
+	return result
+}
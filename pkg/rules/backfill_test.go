@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestEvaluateRecordingRules(t *testing.T) {
+	namespaces := map[string]RuleNamespace{
+		"ns": {
+			Namespace: "ns",
+			Groups: []rwrulefmt.RuleGroup{{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "group",
+					Rules: []rulefmt.RuleNode{
+						{
+							Record: yaml.Node{Value: "job:up:sum"},
+							Expr:   yaml.Node{Value: "sum by (job, env) (up)"},
+							Labels: map[string]string{"source": "backfill", "env": ""},
+						},
+						{Alert: yaml.Node{Value: "Down"}, Expr: yaml.Node{Value: "up == 0"}},
+					},
+				},
+			}},
+		},
+	}
+
+	q := &fakeRangeQuerier{results: map[string]model.Matrix{
+		"sum by (job, env) (up)": {{
+			Metric: model.Metric{"job": "a", "env": "prod"},
+			Values: []model.SamplePair{{Timestamp: 0, Value: 2}, {Timestamp: 60000, Value: 3}},
+		}},
+	}}
+
+	matrix, err := EvaluateRecordingRules(context.Background(), q, namespaces, time.Unix(0, 0), time.Unix(60, 0), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, model.Matrix{{
+		Metric: model.Metric{"__name__": "job:up:sum", "job": "a", "source": "backfill"},
+		Values: []model.SamplePair{{Timestamp: 0, Value: 2}, {Timestamp: 60000, Value: 3}},
+	}}, matrix)
+
+	// Queries failing abort the evaluation.
+	_, err = EvaluateRecordingRules(context.Background(), &fakeRangeQuerier{}, namespaces, time.Unix(0, 0), time.Unix(60, 0), time.Minute)
+	require.EqualError(t, err, "unable to evaluate recording rule job:up:sum in group group of namespace ns: unknown query")
+}
+
+func TestEvaluateRecordingRules_SameSeries(t *testing.T) {
+	// Both rules record job:up:sum{job="a"}, one from a series labelled by
+	// the rule and with a sample at the same timestamp as the other rule.
+	namespaces := map[string]RuleNamespace{
+		"ns": {
+			Namespace: "ns",
+			Groups: []rwrulefmt.RuleGroup{
+				{RuleGroup: rulefmt.RuleGroup{
+					Name: "first",
+					Rules: []rulefmt.RuleNode{
+						{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: "sum by (job) (up)"}},
+					},
+				}},
+				{RuleGroup: rulefmt.RuleGroup{
+					Name: "second",
+					Rules: []rulefmt.RuleNode{{
+						Record: yaml.Node{Value: "job:up:sum"},
+						Expr:   yaml.Node{Value: "sum(up{job=\"b\"})"},
+						Labels: map[string]string{"job": "a"},
+					}},
+				}},
+			},
+		},
+	}
+
+	q := &fakeRangeQuerier{results: map[string]model.Matrix{
+		"sum by (job) (up)": {{
+			Metric: model.Metric{"job": "a"},
+			Values: []model.SamplePair{{Timestamp: 60000, Value: 1}, {Timestamp: 180000, Value: 3}},
+		}},
+		"sum(up{job=\"b\"})": {{
+			Metric: model.Metric{},
+			Values: []model.SamplePair{{Timestamp: 0, Value: 10}, {Timestamp: 60000, Value: 20}, {Timestamp: 120000, Value: 30}},
+		}},
+	}}
+
+	matrix, err := EvaluateRecordingRules(context.Background(), q, namespaces, time.Unix(0, 0), time.Unix(180, 0), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, model.Matrix{{
+		Metric: model.Metric{"__name__": "job:up:sum", "job": "a"},
+		Values: []model.SamplePair{
+			{Timestamp: 0, Value: 10},
+			{Timestamp: 60000, Value: 1},
+			{Timestamp: 120000, Value: 30},
+			{Timestamp: 180000, Value: 3},
+		},
+	}}, matrix)
+}
+
+func TestRecordedDependencies(t *testing.T) {
+	recorded := map[string]struct{}{"job:up:sum": {}, "job:up:avg": {}}
+	require.Equal(t, []string{"job:up:sum"}, recordedDependencies(`job:up:sum / on (job) group_left count(up)`, recorded))
+	require.Empty(t, recordedDependencies(`{__name__=~"job:up:.*"}`, recorded))
+}
@@ -0,0 +1,217 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// maxPointsPerQuery is the maximum number of points per series a single range
+// query may return, longer windows are split across several queries.
+const maxPointsPerQuery = 11000
+
+// RangeQuerier executes PromQL range queries.
+type RangeQuerier interface {
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error)
+}
+
+// BacktestOptions configures the backtest of alerting rules.
+type BacktestOptions struct {
+	Start time.Time
+	End   time.Time
+	// DefaultInterval is the evaluation interval of the groups that don't set one.
+	DefaultInterval time.Duration
+	// FlapWindow is the maximum time between an alert resolving and firing
+	// again for it to be counted as a flap.
+	FlapWindow time.Duration
+}
+
+// BacktestResult is the outcome of evaluating an alerting rule over a past window.
+type BacktestResult struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Group     string `json:"group" yaml:"group"`
+	Alert     string `json:"alert" yaml:"alert"`
+	// Series is the number of distinct series for which the alert fired.
+	Series int `json:"series" yaml:"series"`
+	// Firings is the number of times the alert started firing.
+	Firings    int            `json:"firings" yaml:"firings"`
+	FiringTime model.Duration `json:"firing_time" yaml:"firing_time"`
+	// Flaps is the number of times the alert fired again within the flap
+	// window after resolving.
+	Flaps int    `json:"flaps" yaml:"flaps"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Backtest evaluates every alerting rule of the namespaces against the data
+// returned by the querier. Each rule expression is run as a range query with
+// the evaluation interval of its group as step. Results are sorted by
+// namespace and keep the order of the groups and rules within a namespace.
+func Backtest(ctx context.Context, q RangeQuerier, namespaces map[string]RuleNamespace, opts BacktestOptions) []BacktestResult {
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var results []BacktestResult
+	for _, name := range names {
+		for _, g := range namespaces[name].Groups {
+			step := time.Duration(g.Interval)
+			if step == 0 {
+				step = opts.DefaultInterval
+			}
+
+			for _, r := range g.Rules {
+				if r.Alert.Value == "" {
+					continue
+				}
+
+				result := BacktestResult{
+					Namespace: name,
+					Group:     g.Name,
+					Alert:     r.Alert.Value,
+				}
+
+				matrix, err := queryRange(ctx, q, r.Expr.Value, opts.Start, opts.End, step)
+				if err != nil {
+					result.Error = err.Error()
+					results = append(results, result)
+					continue
+				}
+
+				stats := BacktestAlert(matrix, opts.Start, opts.End, step, time.Duration(r.For), time.Duration(r.KeepFiringFor), opts.FlapWindow)
+				result.Series = stats.Series
+				result.Firings = stats.Firings
+				result.FiringTime = model.Duration(stats.FiringTime)
+				result.Flaps = stats.Flaps
+				results = append(results, result)
+			}
+		}
+	}
+
+	return results
+}
+
+// queryRange runs a range query, splitting it in several queries if the
+// window has more than maxPointsPerQuery steps. The series of every query are
+// merged.
+func queryRange(ctx context.Context, q RangeQuerier, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	var result model.Matrix
+	series := map[model.Fingerprint]*model.SampleStream{}
+
+	for chunkStart := start; !chunkStart.After(end); chunkStart = chunkStart.Add(maxPointsPerQuery * step) {
+		chunkEnd := chunkStart.Add((maxPointsPerQuery - 1) * step)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+
+		matrix, err := q.QueryRange(ctx, query, chunkStart, chunkEnd, step)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range matrix {
+			fp := s.Metric.Fingerprint()
+			if existing, ok := series[fp]; ok {
+				existing.Values = append(existing.Values, s.Values...)
+				continue
+			}
+			series[fp] = s
+			result = append(result, s)
+		}
+	}
+
+	return result, nil
+}
+
+// AlertStats summarizes how an alerting rule would have behaved.
+type AlertStats struct {
+	Series     int
+	Firings    int
+	FiringTime time.Duration
+	Flaps      int
+}
+
+// BacktestAlert replays the evaluations of an alerting rule between start and
+// end, every step, given the result of its expression as a range query. A
+// series is active at an evaluation if the matrix has a sample for it at that
+// time. Samples are mapped to the nearest evaluation, as query frontends may
+// align the range queries to the step rather than to start. Like in the
+// Prometheus rule manager, an active series starts firing once it has been
+// active for the hold duration and, once firing, keeps firing for keepFiringFor
+// after it is no longer active. Alerts still firing at the end of the window
+// are counted up to the last evaluation.
+func BacktestAlert(matrix model.Matrix, start, end time.Time, step, hold, keepFiringFor, flapWindow time.Duration) AlertStats {
+	var stats AlertStats
+
+	for _, s := range matrix {
+		active := make(map[int64]struct{}, len(s.Values))
+		for _, v := range s.Values {
+			active[evaluationSlot(v.Timestamp.Time(), start, step)] = struct{}{}
+		}
+
+		var (
+			pending, firing                    bool
+			activeAt, firedAt, keepFiringSince time.Time
+			resolvedAt                         time.Time
+			fired                              bool
+			last                               time.Time
+		)
+
+		for slot, ts := int64(0), start; !ts.After(end); slot, ts = slot+1, ts.Add(step) {
+			last = ts
+
+			if _, ok := active[slot]; ok {
+				keepFiringSince = time.Time{}
+				if !pending && !firing {
+					pending = true
+					activeAt = ts
+				}
+				if pending && ts.Sub(activeAt) >= hold {
+					pending, firing = false, true
+					firedAt = ts
+					fired = true
+					stats.Firings++
+					if !resolvedAt.IsZero() && ts.Sub(resolvedAt) <= flapWindow {
+						stats.Flaps++
+					}
+				}
+				continue
+			}
+
+			if firing && keepFiringFor > 0 {
+				if keepFiringSince.IsZero() {
+					keepFiringSince = ts
+				}
+				if ts.Sub(keepFiringSince) < keepFiringFor {
+					continue
+				}
+			}
+
+			if firing {
+				stats.FiringTime += ts.Sub(firedAt)
+				resolvedAt = ts
+			}
+			pending, firing = false, false
+			keepFiringSince = time.Time{}
+		}
+
+		if firing {
+			stats.FiringTime += last.Sub(firedAt)
+		}
+		if fired {
+			stats.Series++
+		}
+	}
+
+	return stats
+}
+
+// evaluationSlot returns the index of the evaluation nearest to ts, of the
+// evaluations every step from start.
+func evaluationSlot(ts, start time.Time, step time.Duration) int64 {
+	return int64(math.Round(float64(ts.Sub(start)) / float64(step)))
+}
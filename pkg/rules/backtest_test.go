@@ -0,0 +1,158 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// activeSeries returns a series with a sample at every minute for which active is true.
+func activeSeries(job string, active ...bool) *model.SampleStream {
+	s := &model.SampleStream{Metric: model.Metric{"job": model.LabelValue(job)}}
+	for i, a := range active {
+		if a {
+			s.Values = append(s.Values, model.SamplePair{Timestamp: model.Time(int64(i) * time.Minute.Milliseconds()), Value: 1})
+		}
+	}
+	return s
+}
+
+func TestBacktestAlert(t *testing.T) {
+	start := time.Unix(0, 0)
+	end := start.Add(9 * time.Minute)
+
+	tests := []struct {
+		name          string
+		matrix        model.Matrix
+		hold          time.Duration
+		keepFiringFor time.Duration
+		expected      AlertStats
+	}{
+		{
+			name:     "never active",
+			matrix:   model.Matrix{},
+			expected: AlertStats{},
+		},
+		{
+			name:     "fires immediately without for",
+			matrix:   model.Matrix{activeSeries("a", false, true, true, false)},
+			expected: AlertStats{Series: 1, Firings: 1, FiringTime: 2 * time.Minute},
+		},
+		{
+			name:     "for duration delays firing",
+			matrix:   model.Matrix{activeSeries("a", true, true, true, false, true, true)},
+			hold:     2 * time.Minute,
+			expected: AlertStats{Series: 1, Firings: 1, FiringTime: time.Minute},
+		},
+		{
+			name:     "flapping",
+			matrix:   model.Matrix{activeSeries("a", true, false, true, false, false, false, false, false, false, true)},
+			expected: AlertStats{Series: 1, Firings: 3, FiringTime: 2 * time.Minute, Flaps: 1},
+		},
+		{
+			name:          "keep firing for bridges gaps",
+			matrix:        model.Matrix{activeSeries("a", true, false, true, false, false, false)},
+			keepFiringFor: 2 * time.Minute,
+			expected:      AlertStats{Series: 1, Firings: 1, FiringTime: 5 * time.Minute},
+		},
+		{
+			name: "several series",
+			matrix: model.Matrix{
+				activeSeries("a", true, true),
+				activeSeries("b", false, false, false, false, false, false, false, false, true, true),
+			},
+			expected: AlertStats{Series: 2, Firings: 2, FiringTime: 3 * time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := BacktestAlert(tt.matrix, start, end, time.Minute, tt.hold, tt.keepFiringFor, 5*time.Minute)
+			require.Equal(t, tt.expected, stats)
+		})
+	}
+}
+
+type fakeRangeQuerier struct {
+	queries int
+	results map[string]model.Matrix
+}
+
+func (f *fakeRangeQuerier) QueryRange(_ context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	f.queries++
+	m, ok := f.results[query]
+	if !ok {
+		return nil, errors.New("unknown query")
+	}
+
+	// Only return the samples within the queried window.
+	var result model.Matrix
+	for _, s := range m {
+		var values []model.SamplePair
+		for _, v := range s.Values {
+			if !v.Timestamp.Time().Before(start) && !v.Timestamp.Time().After(end) {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			result = append(result, &model.SampleStream{Metric: s.Metric, Values: values})
+		}
+	}
+	return result, nil
+}
+
+func TestBacktestAlert_StepAlignedMatrix(t *testing.T) {
+	// The query frontend aligned the query to the step, so samples are 17s
+	// before the evaluations.
+	start := time.Unix(17, 0)
+	end := start.Add(9 * time.Minute)
+
+	matrix := model.Matrix{activeSeries("a", false, true, true, false)}
+	stats := BacktestAlert(matrix, start, end, time.Minute, 0, 0, 5*time.Minute)
+	require.Equal(t, AlertStats{Series: 1, Firings: 1, FiringTime: 2 * time.Minute}, stats)
+}
+
+func TestBacktest(t *testing.T) {
+	namespaces := map[string]RuleNamespace{
+		"ns": {
+			Namespace: "ns",
+			Groups: []rwrulefmt.RuleGroup{{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "group",
+					Rules: []rulefmt.RuleNode{
+						{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: "sum by (job) (up)"}},
+						{Alert: yaml.Node{Value: "Down"}, Expr: yaml.Node{Value: "up == 0"}},
+						{Alert: yaml.Node{Value: "Broken"}, Expr: yaml.Node{Value: "broken"}},
+					},
+				},
+			}},
+		},
+	}
+
+	// The second sample is beyond the points of a single query, so it is
+	// returned by a second query.
+	series := activeSeries("a", true)
+	series.Values = append(series.Values, model.SamplePair{Timestamp: model.Time(maxPointsPerQuery * time.Minute.Milliseconds()), Value: 1})
+	q := &fakeRangeQuerier{results: map[string]model.Matrix{"up == 0": {series}}}
+
+	results := Backtest(context.Background(), q, namespaces, BacktestOptions{
+		Start:           time.Unix(0, 0),
+		End:             time.Unix(0, 0).Add((maxPointsPerQuery + 1) * time.Minute),
+		DefaultInterval: time.Minute,
+		FlapWindow:      time.Hour,
+	})
+
+	require.Equal(t, []BacktestResult{
+		{Namespace: "ns", Group: "group", Alert: "Down", Series: 1, Firings: 2, FiringTime: model.Duration(2 * time.Minute)},
+		{Namespace: "ns", Group: "group", Alert: "Broken", Error: "unknown query"},
+	}, results)
+	require.Equal(t, 3, q.queries)
+}
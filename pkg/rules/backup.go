@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// BackupManifestFile is the name of the manifest of a backup directory.
+const BackupManifestFile = "manifest.yaml"
+
+// BackupManifest describes the rules backed up in a directory.
+type BackupManifest struct {
+	Tenant    string    `yaml:"tenant"`
+	Address   string    `yaml:"address"`
+	Backend   string    `yaml:"backend"`
+	Timestamp time.Time `yaml:"timestamp"`
+	// Namespaces are the backed up namespaces, sorted by name.
+	Namespaces []BackupNamespace `yaml:"namespaces"`
+}
+
+// BackupNamespace is a namespace of a backup.
+type BackupNamespace struct {
+	Namespace string `yaml:"namespace"`
+	// File is the path of the rule file of the namespace, relative to the
+	// backup directory.
+	File   string `yaml:"file"`
+	Groups int    `yaml:"groups"`
+}
+
+// WriteBackup writes every namespace to a rule file of the directory, with its
+// namespace field set so it can be parsed back, followed by the manifest of the
+// backup. The namespaces of the manifest are replaced by the written ones.
+func WriteBackup(dir string, namespaces map[string][]rwrulefmt.RuleGroup, manifest BackupManifest) (BackupManifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return manifest, err
+	}
+
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest.Namespaces = make([]BackupNamespace, 0, len(names))
+	for _, name := range names {
+		ns := RuleNamespace{Namespace: name, Groups: namespaces[name]}
+		payload, err := yaml.Marshal(ns)
+		if err != nil {
+			return manifest, err
+		}
+
+		// Namespaces may contain characters that are not allowed in file names.
+		file := url.PathEscape(name) + ".yaml"
+		if err := os.WriteFile(filepath.Join(dir, file), payload, 0644); err != nil {
+			return manifest, err
+		}
+
+		manifest.Namespaces = append(manifest.Namespaces, BackupNamespace{
+			Namespace: name,
+			File:      file,
+			Groups:    len(ns.Groups),
+		})
+	}
+
+	payload, err := yaml.Marshal(manifest)
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, BackupManifestFile), payload, 0644)
+}
+
+// ReadBackupManifest reads the manifest of a backup directory. The files of its
+// namespaces are returned with the path of the directory.
+func ReadBackupManifest(dir string) (BackupManifest, error) {
+	var manifest BackupManifest
+
+	payload, err := os.ReadFile(filepath.Join(dir, BackupManifestFile))
+	if err != nil {
+		return manifest, errors.Wrap(err, "unable to read backup manifest")
+	}
+	if err := yaml.Unmarshal(payload, &manifest); err != nil {
+		return manifest, errors.Wrap(err, "unable to parse backup manifest")
+	}
+
+	for i, ns := range manifest.Namespaces {
+		if ns.File == "" || filepath.IsAbs(ns.File) {
+			return manifest, fmt.Errorf("invalid file %q for namespace %s in backup manifest", ns.File, ns.Namespace)
+		}
+		manifest.Namespaces[i].File = filepath.Join(dir, ns.File)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestBackup(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backup")
+	namespaces := map[string][]rwrulefmt.RuleGroup{
+		"team/api": {{
+			RuleGroup: rulefmt.RuleGroup{
+				Name:     "api",
+				Interval: model.Duration(time.Minute),
+				Rules:    []rulefmt.RuleNode{{Record: yaml.Node{Kind: yaml.ScalarNode, Value: "job:up:sum"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "sum by (job) (up)"}}},
+			},
+			SourceTenants: []string{"tenant-a"},
+		}},
+		"node": {
+			{RuleGroup: rulefmt.RuleGroup{Name: "a", Rules: []rulefmt.RuleNode{{Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "NodeDown"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "up == 0"}}}}},
+			{RuleGroup: rulefmt.RuleGroup{Name: "b", Rules: []rulefmt.RuleNode{{Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "NodeSlow"}, Expr: yaml.Node{Kind: yaml.ScalarNode, Value: "load1 > 10"}}}}},
+		},
+	}
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	manifest, err := WriteBackup(dir, namespaces, BackupManifest{Tenant: "tenant", Address: "http://cortex", Backend: CortexBackend, Timestamp: timestamp})
+	require.NoError(t, err)
+	require.Equal(t, []BackupNamespace{
+		{Namespace: "node", File: "node.yaml", Groups: 2},
+		{Namespace: "team/api", File: "team%2Fapi.yaml", Groups: 1},
+	}, manifest.Namespaces)
+
+	payload, err := os.ReadFile(filepath.Join(dir, BackupManifestFile))
+	require.NoError(t, err)
+	require.Equal(t, `tenant: tenant
+address: http://cortex
+backend: cortex
+timestamp: 2026-01-02T03:04:05Z
+namespaces:
+    - namespace: node
+      file: node.yaml
+      groups: 2
+    - namespace: team/api
+      file: team%2Fapi.yaml
+      groups: 1
+`, string(payload))
+
+	read, err := ReadBackupManifest(dir)
+	require.NoError(t, err)
+	require.Equal(t, "tenant", read.Tenant)
+	require.Equal(t, timestamp, read.Timestamp)
+	require.Equal(t, filepath.Join(dir, "node.yaml"), read.Namespaces[0].File)
+
+	files := []string{read.Namespaces[0].File, read.Namespaces[1].File}
+	nss, err := ParseFiles(CortexBackend, files)
+	require.NoError(t, err)
+	require.Len(t, nss, 2)
+	for name, groups := range namespaces {
+		require.Equal(t, name, nss[name].Namespace)
+		require.Len(t, nss[name].Groups, len(groups))
+		for i := range groups {
+			require.NoError(t, CompareGroups(groups[i], nss[name].Groups[i]))
+		}
+	}
+}
+
+func TestReadBackupManifest_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := ReadBackupManifest(dir)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, BackupManifestFile), []byte("namespaces:\n  - namespace: ns\n    file: /etc/passwd\n"), 0644))
+	_, err = ReadBackupManifest(dir)
+	require.EqualError(t, err, `invalid file "/etc/passwd" for namespace ns in backup manifest`)
+}
@@ -4,10 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
+	logql "github.com/grafana/loki/pkg/logql/syntax"
 	"github.com/mitchellh/colorstring"
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
 	yaml "gopkg.in/yaml.v3"
 
 	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
@@ -18,6 +21,8 @@ var (
 	errIntervalDiff  = errors.New("rule groups have different intervals")
 	errDiffRuleLen   = errors.New("rule groups have a different number of rules")
 	errDiffRWConfigs = errors.New("rule groups has different remote write configs")
+	errLimitDiff     = errors.New("rule groups have different limits")
+	errDiffSources   = errors.New("rule groups have different source tenants")
 )
 
 // NamespaceState is used to denote the difference between the staged namespace
@@ -66,10 +71,62 @@ func SummarizeChanges(changes []NamespaceChange) (created, updated, deleted int)
 type UpdatedRuleGroup struct {
 	New      rwrulefmt.RuleGroup
 	Original rwrulefmt.RuleGroup
+
+	// opts are the options the groups were compared with.
+	opts CompareOptions
+}
+
+// CompareOptions configures how rule groups are compared.
+type CompareOptions struct {
+	// Backend is the backend whose query language the expressions are parsed
+	// with, cortex if empty.
+	Backend string
+	// StrictExprComparison compares expressions as text instead of comparing
+	// their parsed form.
+	StrictExprComparison bool
+}
+
+// exprEqual returns whether two expressions are equal. Unless the comparison
+// is strict, expressions that parse to the same query are equal regardless of
+// their formatting. Expressions that can't be parsed are compared as text.
+func (o CompareOptions) exprEqual(a, b string) bool {
+	if a == b || o.StrictExprComparison {
+		return a == b
+	}
+
+	normalizedA, errA := normalizeExpr(o.Backend, a)
+	normalizedB, errB := normalizeExpr(o.Backend, b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return normalizedA == normalizedB
+}
+
+// normalizeExpr returns the canonical representation of a query.
+func normalizeExpr(backend, expr string) (string, error) {
+	if backend == LokiBackend {
+		parsed, err := logql.ParseExpr(expr)
+		if err != nil {
+			return "", err
+		}
+		return parsed.String(), nil
+	}
+
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
 }
 
 // CompareGroups differentiates between two rule groups
 func CompareGroups(groupOne, groupTwo rwrulefmt.RuleGroup) error {
+	return CompareGroupsWithOptions(groupOne, groupTwo, CompareOptions{})
+}
+
+// CompareGroupsWithOptions differentiates between two rule groups, comparing
+// their expressions according to the options.
+func CompareGroupsWithOptions(groupOne, groupTwo rwrulefmt.RuleGroup, opts CompareOptions) error {
 	if groupOne.Name != groupTwo.Name {
 		return errNameDiff
 	}
@@ -92,8 +149,16 @@ func CompareGroups(groupOne, groupTwo rwrulefmt.RuleGroup) error {
 		}
 	}
 
+	if groupOne.Limit != groupTwo.Limit {
+		return errLimitDiff
+	}
+
+	if !reflect.DeepEqual(sortedSourceTenants(groupOne), sortedSourceTenants(groupTwo)) {
+		return errDiffSources
+	}
+
 	for i := range groupOne.Rules {
-		eq := rulesEqual(&groupOne.Rules[i], &groupTwo.Rules[i])
+		eq := rulesEqual(&groupOne.Rules[i], &groupTwo.Rules[i], opts)
 		if !eq {
 			return fmt.Errorf("rule #%v does not match %v != %v", i, groupOne.Rules[i], groupTwo.Rules[i])
 		}
@@ -102,10 +167,21 @@ func CompareGroups(groupOne, groupTwo rwrulefmt.RuleGroup) error {
 	return nil
 }
 
-func rulesEqual(a, b *rulefmt.RuleNode) bool {
+// sortedSourceTenants returns the source tenants of a rule group in order, as
+// their order doesn't matter. It returns nil if the group is not federated.
+func sortedSourceTenants(g rwrulefmt.RuleGroup) []string {
+	if len(g.SourceTenants) == 0 {
+		return nil
+	}
+	tenants := append([]string(nil), g.SourceTenants...)
+	sort.Strings(tenants)
+	return tenants
+}
+
+func rulesEqual(a, b *rulefmt.RuleNode, opts CompareOptions) bool {
 	if a.Alert.Value != b.Alert.Value ||
 		a.Record.Value != b.Record.Value ||
-		a.Expr.Value != b.Expr.Value ||
+		!opts.exprEqual(a.Expr.Value, b.Expr.Value) ||
 		a.For != b.For {
 		return false
 	}
@@ -132,6 +208,12 @@ func rulesEqual(a, b *rulefmt.RuleNode) bool {
 // CompareNamespaces returns the differences between the two provided
 // namespaces
 func CompareNamespaces(original, new RuleNamespace) NamespaceChange {
+	return CompareNamespacesWithOptions(original, new, CompareOptions{})
+}
+
+// CompareNamespacesWithOptions returns the differences between the two
+// provided namespaces, comparing their expressions according to the options.
+func CompareNamespacesWithOptions(original, new RuleNamespace, opts CompareOptions) NamespaceChange {
 	result := NamespaceChange{
 		Namespace:     new.Namespace,
 		State:         Unchanged,
@@ -152,12 +234,13 @@ func CompareNamespaces(original, new RuleNamespace) NamespaceChange {
 			result.GroupsCreated = append(result.GroupsCreated, newGroup)
 			continue
 		}
-		diff := CompareGroups(newGroup, origGroup)
+		diff := CompareGroupsWithOptions(newGroup, origGroup, opts)
 		if diff != nil {
 			result.State = Updated
 			result.GroupsUpdated = append(result.GroupsUpdated, UpdatedRuleGroup{
 				Original: origGroup,
 				New:      newGroup,
+				opts:     opts,
 			})
 		}
 		delete(origMap, newGroup.Name)
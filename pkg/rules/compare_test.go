@@ -5,6 +5,7 @@ import (
 
 	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -86,7 +87,7 @@ func Test_rulesEqual(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := rulesEqual(tt.a, tt.b); got != tt.want {
+			if got := rulesEqual(tt.a, tt.b, CompareOptions{}); got != tt.want {
 				t.Errorf("rulesEqual() = %v, want %v", got, tt.want)
 			}
 		})
@@ -275,6 +276,40 @@ func TestCompareGroups(t *testing.T) {
 			},
 			expectedErr: errDiffRWConfigs,
 		},
+		{
+			name: "different limits",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{Name: "example_group", Limit: 10},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup: rulefmt.RuleGroup{Name: "example_group"},
+			},
+			expectedErr: errLimitDiff,
+		},
+		{
+			name: "source tenants in a different order",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup:     rulefmt.RuleGroup{Name: "example_group"},
+				SourceTenants: []string{"tenant-a", "tenant-b"},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup:     rulefmt.RuleGroup{Name: "example_group"},
+				SourceTenants: []string{"tenant-b", "tenant-a"},
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "different source tenants",
+			groupOne: rwrulefmt.RuleGroup{
+				RuleGroup:     rulefmt.RuleGroup{Name: "example_group"},
+				SourceTenants: []string{"tenant-a", "tenant-b"},
+			},
+			groupTwo: rwrulefmt.RuleGroup{
+				RuleGroup:     rulefmt.RuleGroup{Name: "example_group"},
+				SourceTenants: []string{"tenant-a"},
+			},
+			expectedErr: errDiffSources,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -286,3 +321,75 @@ func TestCompareGroups(t *testing.T) {
 		})
 	}
 }
+
+func TestCompareOptions_exprEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		opts CompareOptions
+		a, b string
+		want bool
+	}{
+		{
+			name: "reformatted promql",
+			a:    "sum(rate(http_requests_total{job=\"api\",code=~\"5..\"}[5m])) by (job)",
+			b:    "sum by (job) (\n  rate(http_requests_total{code=~\"5..\", job=\"api\"}[5m])\n)",
+			want: true,
+		},
+		{
+			name: "different promql",
+			a:    "sum by (job) (rate(http_requests_total[5m]))",
+			b:    "sum by (job) (rate(http_requests_total[1m]))",
+			want: false,
+		},
+		{
+			name: "strict comparison",
+			opts: CompareOptions{StrictExprComparison: true},
+			a:    "sum(up) by (job)",
+			b:    "sum by (job) (up)",
+			want: false,
+		},
+		{
+			name: "unparsable expression",
+			a:    "sum(up",
+			b:    "sum( up",
+			want: false,
+		},
+		{
+			name: "reformatted logql",
+			opts: CompareOptions{Backend: LokiBackend},
+			a:    `sum(rate({app="api"} |= "error" [5m])) by (pod)`,
+			b:    `sum by (pod) (rate({app="api"}|="error"[5m]))`,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.opts.exprEqual(tt.a, tt.b))
+		})
+	}
+}
+
+func TestCompareNamespacesWithOptions(t *testing.T) {
+	namespace := func(expr string) RuleNamespace {
+		return RuleNamespace{
+			Namespace: "ns",
+			Groups: []rwrulefmt.RuleGroup{{
+				RuleGroup: rulefmt.RuleGroup{
+					Name:  "group",
+					Rules: []rulefmt.RuleNode{{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: expr}}},
+				},
+			}},
+		}
+	}
+	original, reformatted := namespace("sum(up) by (job)"), namespace("sum by (job) (up)")
+
+	change := CompareNamespacesWithOptions(original, reformatted, CompareOptions{})
+	require.Equal(t, Unchanged, change.State)
+	require.Empty(t, change.GroupsUpdated)
+
+	change = CompareNamespacesWithOptions(original, reformatted, CompareOptions{StrictExprComparison: true})
+	require.Equal(t, Updated, change.State)
+	require.Len(t, change.GroupsUpdated, 1)
+	require.Len(t, change.GroupsUpdated[0].Diff().Rules, 1)
+}
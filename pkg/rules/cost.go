@@ -0,0 +1,236 @@
+package rules
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// InstantQuerier executes PromQL instant queries.
+type InstantQuerier interface {
+	QueryInstant(ctx context.Context, query string, ts time.Time) (model.Vector, error)
+}
+
+// CostOptions configures the cost estimation of rule groups.
+type CostOptions struct {
+	// Time is the evaluation time of the queries.
+	Time time.Time
+	// DefaultInterval is the evaluation interval of the groups that don't set one.
+	DefaultInterval time.Duration
+	// MaxUtilization is the share of its interval a group may spend evaluating
+	// its rules before it is flagged as likely to miss evaluations.
+	MaxUtilization float64
+}
+
+// RuleCost is the estimated cost of evaluating a rule.
+type RuleCost struct {
+	Rule string `json:"rule" yaml:"rule"`
+	// Series is the number of series selected by the expression.
+	Series int `json:"series" yaml:"series"`
+	// OutputSeries is the number of series returned by the expression.
+	OutputSeries int            `json:"output_series" yaml:"output_series"`
+	Latency      model.Duration `json:"latency" yaml:"latency"`
+	Error        string         `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// GroupCost is the estimated cost of evaluating a rule group.
+type GroupCost struct {
+	Namespace string         `json:"namespace" yaml:"namespace"`
+	Group     string         `json:"group" yaml:"group"`
+	Interval  model.Duration `json:"interval" yaml:"interval"`
+	Rules     []RuleCost     `json:"rules" yaml:"rules"`
+	Series    int            `json:"series" yaml:"series"`
+	Latency   model.Duration `json:"latency" yaml:"latency"`
+	// Utilization is the share of the interval spent evaluating the rules.
+	Utilization float64 `json:"utilization" yaml:"utilization"`
+	// AtRisk is set if the utilization is above the maximum.
+	AtRisk bool `json:"at_risk" yaml:"at_risk"`
+	// SuggestedSplit lists the rules of the groups the group could be split
+	// into to keep their utilization below the maximum.
+	SuggestedSplit [][]string `json:"suggested_split,omitempty" yaml:"suggested_split,omitempty"`
+}
+
+// EstimateCosts estimates the cost of every rule group of the namespaces.
+// Every expression is run as an instant query to measure its latency, and the
+// number of series of each of its selectors is counted. Groups are sorted by
+// namespace and keep their order within a namespace.
+func EstimateCosts(ctx context.Context, q InstantQuerier, namespaces map[string]RuleNamespace, opts CostOptions) []GroupCost {
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var results []GroupCost
+	for _, name := range names {
+		for _, g := range namespaces[name].Groups {
+			interval := time.Duration(g.Interval)
+			if interval == 0 {
+				interval = opts.DefaultInterval
+			}
+
+			result := GroupCost{
+				Namespace: name,
+				Group:     g.Name,
+				Interval:  model.Duration(interval),
+				Rules:     make([]RuleCost, 0, len(g.Rules)),
+			}
+
+			for _, r := range g.Rules {
+				cost := estimateRuleCost(ctx, q, r.Expr.Value, opts.Time)
+				cost.Rule = getRuleName(r)
+				result.Rules = append(result.Rules, cost)
+				result.Series += cost.Series
+				result.Latency += cost.Latency
+			}
+
+			if interval > 0 {
+				result.Utilization = float64(result.Latency) / float64(interval)
+			}
+			if result.Utilization > opts.MaxUtilization {
+				result.AtRisk = true
+				result.SuggestedSplit = SuggestSplit(g.Rules, result.Rules, time.Duration(float64(interval)*opts.MaxUtilization))
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+func estimateRuleCost(ctx context.Context, q InstantQuerier, expr string, ts time.Time) RuleCost {
+	var cost RuleCost
+
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		cost.Error = err.Error()
+		return cost
+	}
+
+	seen := map[string]struct{}{}
+	for _, matchers := range parser.ExtractSelectors(parsed) {
+		selector := (&parser.VectorSelector{LabelMatchers: matchers}).String()
+		if _, ok := seen[selector]; ok {
+			continue
+		}
+		seen[selector] = struct{}{}
+
+		vector, err := q.QueryInstant(ctx, "count("+selector+")", ts)
+		if err != nil {
+			cost.Error = err.Error()
+			return cost
+		}
+		for _, s := range vector {
+			cost.Series += int(s.Value)
+		}
+	}
+
+	start := time.Now()
+	vector, err := q.QueryInstant(ctx, expr, ts)
+	cost.Latency = model.Duration(time.Since(start))
+	if err != nil {
+		cost.Error = err.Error()
+		return cost
+	}
+	cost.OutputSeries = len(vector)
+
+	return cost
+}
+
+// SuggestSplit distributes the rules of a group into the smallest number of
+// groups that could each be evaluated within capacity, given the cost of the
+// rules. Rules using the output of a recording rule of the group are kept in
+// the same group as it, so they still get its latest result. Rules keep their
+// order within a group. It returns nil if the rules can't be split.
+func SuggestSplit(rules []rulefmt.RuleNode, costs []RuleCost, capacity time.Duration) [][]string {
+	if len(rules) != len(costs) || capacity <= 0 {
+		return nil
+	}
+
+	// Units are the sets of rules that must stay together, found with a
+	// union-find over the dependencies within the group.
+	parent := make([]int, len(rules))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	recorded := map[string]struct{}{}
+	recorders := map[string][]int{}
+	for i, r := range rules {
+		if r.Record.Value != "" {
+			recorded[r.Record.Value] = struct{}{}
+			recorders[r.Record.Value] = append(recorders[r.Record.Value], i)
+		}
+	}
+	for i, r := range rules {
+		for _, metric := range recordedDependencies(r.Expr.Value, recorded) {
+			for _, j := range recorders[metric] {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	type unit struct {
+		rules   []int
+		latency time.Duration
+	}
+	var units []*unit
+	byRoot := map[int]*unit{}
+	var total time.Duration
+	for i := range rules {
+		root := find(i)
+		u, ok := byRoot[root]
+		if !ok {
+			u = &unit{}
+			byRoot[root] = u
+			units = append(units, u)
+		}
+		u.rules = append(u.rules, i)
+		u.latency += time.Duration(costs[i].Latency)
+		total += time.Duration(costs[i].Latency)
+	}
+
+	n := int(math.Ceil(float64(total) / float64(capacity)))
+	if n > len(units) {
+		n = len(units)
+	}
+	if n < 2 {
+		return nil
+	}
+
+	// Assign the most expensive units first, each to the least loaded group.
+	sort.SliceStable(units, func(i, j int) bool { return units[i].latency > units[j].latency })
+	loads := make([]time.Duration, n)
+	assigned := make([]int, len(rules))
+	for _, u := range units {
+		least := 0
+		for i := range loads {
+			if loads[i] < loads[least] {
+				least = i
+			}
+		}
+		loads[least] += u.latency
+		for _, r := range u.rules {
+			assigned[r] = least
+		}
+	}
+
+	split := make([][]string, n)
+	for i, r := range rules {
+		split[assigned[i]] = append(split[assigned[i]], getRuleName(r))
+	}
+	return split
+}
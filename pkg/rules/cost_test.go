@@ -0,0 +1,120 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+type fakeInstantQuerier struct {
+	queries []string
+	results map[string]model.Vector
+	delay   time.Duration
+}
+
+func (q *fakeInstantQuerier) QueryInstant(_ context.Context, query string, _ time.Time) (model.Vector, error) {
+	q.queries = append(q.queries, query)
+	time.Sleep(q.delay)
+
+	result, ok := q.results[query]
+	if !ok {
+		return nil, errors.New("unexpected query")
+	}
+	return result, nil
+}
+
+func TestEstimateCosts(t *testing.T) {
+	q := &fakeInstantQuerier{
+		results: map[string]model.Vector{
+			`count({__name__="up"})`:                   {{Value: 10}},
+			`count({__name__="up",job="api"})`:         {{Value: 4}},
+			`sum by (job) (up) / count(up{job="api"})`: {{Metric: model.Metric{"job": "api"}}},
+			`up == 0`: {},
+		},
+		delay: 10 * time.Millisecond,
+	}
+
+	namespaces := map[string]RuleNamespace{
+		"ns": {Groups: []rwrulefmt.RuleGroup{{
+			RuleGroup: rulefmt.RuleGroup{
+				Name: "group",
+				Rules: []rulefmt.RuleNode{
+					{Record: yaml.Node{Value: "job:up:ratio"}, Expr: yaml.Node{Value: `sum by (job) (up) / count(up{job="api"})`}},
+					{Alert: yaml.Node{Value: "Down"}, Expr: yaml.Node{Value: `up == 0`}},
+					{Alert: yaml.Node{Value: "Broken"}, Expr: yaml.Node{Value: `missing`}},
+				},
+			},
+		}}},
+	}
+
+	results := EstimateCosts(context.Background(), q, namespaces, CostOptions{Time: time.Unix(0, 0), DefaultInterval: 20 * time.Millisecond, MaxUtilization: 0.8})
+	require.Len(t, results, 1)
+
+	res := results[0]
+	require.Equal(t, "ns", res.Namespace)
+	require.Equal(t, "group", res.Group)
+	require.Equal(t, model.Duration(20*time.Millisecond), res.Interval)
+	require.Equal(t, 24, res.Series)
+	require.True(t, res.AtRisk)
+	require.GreaterOrEqual(t, res.Utilization, 1.0)
+	require.NotEmpty(t, res.SuggestedSplit)
+
+	require.Len(t, res.Rules, 3)
+	require.Equal(t, "job:up:ratio", res.Rules[0].Rule)
+	require.Equal(t, 14, res.Rules[0].Series)
+	require.Equal(t, 1, res.Rules[0].OutputSeries)
+	require.GreaterOrEqual(t, time.Duration(res.Rules[0].Latency), 10*time.Millisecond)
+	require.Empty(t, res.Rules[0].Error)
+	require.Equal(t, "Down", res.Rules[1].Rule)
+	require.Equal(t, 10, res.Rules[1].Series)
+	require.Equal(t, 0, res.Rules[1].OutputSeries)
+	require.Equal(t, "Broken", res.Rules[2].Rule)
+	require.Equal(t, "unexpected query", res.Rules[2].Error)
+
+	// Selectors used several times by an expression are only counted once.
+	require.Equal(t, []string{
+		`count({__name__="up"})`,
+		`count({__name__="up",job="api"})`,
+		`sum by (job) (up) / count(up{job="api"})`,
+		`count({__name__="up"})`,
+		`up == 0`,
+		`count({__name__="missing"})`,
+	}, q.queries)
+}
+
+func TestSuggestSplit(t *testing.T) {
+	rules := []rulefmt.RuleNode{
+		{Record: yaml.Node{Value: "a:sum"}, Expr: yaml.Node{Value: "sum(a)"}},
+		{Alert: yaml.Node{Value: "ALow"}, Expr: yaml.Node{Value: "a:sum < 1"}},
+		{Record: yaml.Node{Value: "b:sum"}, Expr: yaml.Node{Value: "sum(b)"}},
+		{Record: yaml.Node{Value: "c:sum"}, Expr: yaml.Node{Value: "sum(c)"}},
+		{Record: yaml.Node{Value: "d:sum"}, Expr: yaml.Node{Value: "sum(d)"}},
+	}
+	costs := []RuleCost{
+		{Latency: model.Duration(4 * time.Second)},
+		{Latency: model.Duration(2 * time.Second)},
+		{Latency: model.Duration(5 * time.Second)},
+		{Latency: model.Duration(3 * time.Second)},
+		{Latency: model.Duration(1 * time.Second)},
+	}
+
+	require.Equal(t, [][]string{
+		{"a:sum", "ALow"},
+		{"b:sum"},
+		{"c:sum", "d:sum"},
+	}, SuggestSplit(rules, costs, 6*time.Second))
+
+	// The rules fit within capacity.
+	require.Nil(t, SuggestSplit(rules, costs, time.Minute))
+
+	// The rules can't be split.
+	require.Nil(t, SuggestSplit(rules[:2], costs[:2], time.Second))
+}
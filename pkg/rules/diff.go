@@ -0,0 +1,236 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+// in a unified diff.
+const diffContextLines = 3
+
+// GroupDiff stores the group-level fields and rules that differ between two
+// versions of a rule group.
+type GroupDiff struct {
+	Fields []FieldDiff
+	Rules  []RuleDiff
+}
+
+// FieldDiff stores the unified diff of a group-level field.
+type FieldDiff struct {
+	Field string `json:"field" yaml:"field"`
+	Diff  string `json:"diff" yaml:"diff"`
+}
+
+// RuleDiff stores the unified diff of a single rule. Rules are matched by
+// their alert or record name, State is either Created, Updated or Deleted.
+type RuleDiff struct {
+	Name  string         `json:"name" yaml:"name"`
+	Kind  string         `json:"kind" yaml:"kind"`
+	State NamespaceState `json:"state" yaml:"state"`
+	Diff  string         `json:"diff" yaml:"diff"`
+}
+
+const (
+	// AlertingRuleKind is the RuleDiff kind of alerting rules.
+	AlertingRuleKind = "alert"
+	// RecordingRuleKind is the RuleDiff kind of recording rules.
+	RecordingRuleKind = "record"
+)
+
+// ruleView is the representation of a rule used to render diffs. It
+// flattens the yaml nodes of a rulefmt.RuleNode so the output does not depend
+// on how the rule was originally formatted.
+type ruleView struct {
+	Record        string            `yaml:"record,omitempty"`
+	Alert         string            `yaml:"alert,omitempty"`
+	Expr          string            `yaml:"expr"`
+	For           model.Duration    `yaml:"for,omitempty"`
+	KeepFiringFor model.Duration    `yaml:"keep_firing_for,omitempty"`
+	Labels        map[string]string `yaml:"labels,omitempty"`
+	Annotations   map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Diff returns the group-level fields and the rules that changed between the
+// original and the new version of the rule group.
+func (u UpdatedRuleGroup) Diff() GroupDiff {
+	return DiffGroupsWithOptions(u.Original, u.New, u.opts)
+}
+
+// DiffGroups returns the group-level fields and the rules that changed between
+// the original and the new rule group. Rules are matched by name, if several
+// rules share the same name they are matched in order of appearance.
+func DiffGroups(original, new rwrulefmt.RuleGroup) GroupDiff {
+	return DiffGroupsWithOptions(original, new, CompareOptions{})
+}
+
+// DiffGroupsWithOptions returns the group-level fields and the rules that
+// changed between the original and the new rule group, comparing their
+// expressions according to the options.
+func DiffGroupsWithOptions(original, new rwrulefmt.RuleGroup, opts CompareOptions) GroupDiff {
+	var result GroupDiff
+
+	for _, f := range groupFields {
+		before, after := renderField(f.name, f.value(original)), renderField(f.name, f.value(new))
+		if before == after {
+			continue
+		}
+		result.Fields = append(result.Fields, FieldDiff{
+			Field: f.name,
+			Diff:  UnifiedDiff(before, after),
+		})
+	}
+
+	origRules := map[string]*rulefmt.RuleNode{}
+	origKeys := ruleKeys(original.Rules)
+	for i, k := range origKeys {
+		origRules[k] = &original.Rules[i]
+	}
+
+	newKeys := ruleKeys(new.Rules)
+	var keptOrig, keptNew []string
+	for i, k := range newKeys {
+		newRule := &new.Rules[i]
+		origRule, found := origRules[k]
+		if !found {
+			result.Rules = append(result.Rules, newRuleDiff(newRule, Created, "", renderRule(newRule)))
+			continue
+		}
+
+		keptNew = append(keptNew, k)
+		delete(origRules, k)
+		if rulesEqual(origRule, newRule, opts) {
+			continue
+		}
+
+		result.Rules = append(result.Rules, newRuleDiff(newRule, Updated, renderRule(origRule), renderRule(newRule)))
+	}
+
+	for i, k := range origKeys {
+		if _, deleted := origRules[k]; !deleted {
+			keptOrig = append(keptOrig, k)
+			continue
+		}
+		result.Rules = append(result.Rules, newRuleDiff(&original.Rules[i], Deleted, renderRule(&original.Rules[i]), ""))
+	}
+
+	// Rules are evaluated sequentially, so a different order is a change
+	// on its own even if every rule is identical.
+	if strings.Join(keptOrig, "\n") != strings.Join(keptNew, "\n") {
+		result.Fields = append(result.Fields, FieldDiff{
+			Field: "rule order",
+			Diff:  UnifiedDiff(strings.Join(keptOrig, "\n"), strings.Join(keptNew, "\n")),
+		})
+	}
+
+	return result
+}
+
+// ruleStates returns the diff of every rule of a group that is entirely
+// created or deleted.
+func ruleStates(rules []rulefmt.RuleNode, state NamespaceState) []RuleDiff {
+	var result []RuleDiff
+	for i := range rules {
+		before, after := renderRule(&rules[i]), ""
+		if state == Created {
+			before, after = after, before
+		}
+		result = append(result, newRuleDiff(&rules[i], state, before, after))
+	}
+	return result
+}
+
+func newRuleDiff(r *rulefmt.RuleNode, state NamespaceState, before, after string) RuleDiff {
+	kind := RecordingRuleKind
+	if r.Alert.Value != "" {
+		kind = AlertingRuleKind
+	}
+	return RuleDiff{
+		Name:  getRuleName(*r),
+		Kind:  kind,
+		State: state,
+		Diff:  UnifiedDiff(before, after),
+	}
+}
+
+// groupFields lists the group-level fields that are compared when diffing
+// rule groups.
+var groupFields = []struct {
+	name  string
+	value func(g rwrulefmt.RuleGroup) interface{}
+}{
+	{name: "interval", value: func(g rwrulefmt.RuleGroup) interface{} { return g.Interval }},
+	{name: "remote_write", value: func(g rwrulefmt.RuleGroup) interface{} { return g.RWConfigs }},
+	{name: "limit", value: func(g rwrulefmt.RuleGroup) interface{} { return g.Limit }},
+	{name: "source_tenants", value: func(g rwrulefmt.RuleGroup) interface{} { return sortedSourceTenants(g) }},
+}
+
+func renderField(name string, value interface{}) string {
+	out, err := yaml.Marshal(map[string]interface{}{name: value})
+	if err != nil {
+		return fmt.Sprintf("%s: %v", name, value)
+	}
+	return string(out)
+}
+
+func renderRule(r *rulefmt.RuleNode) string {
+	out, err := yaml.Marshal(ruleView{
+		Record:        r.Record.Value,
+		Alert:         r.Alert.Value,
+		Expr:          r.Expr.Value,
+		For:           r.For,
+		KeepFiringFor: r.KeepFiringFor,
+		Labels:        r.Labels,
+		Annotations:   r.Annotations,
+	})
+	if err != nil {
+		return fmt.Sprintf("%v", *r)
+	}
+	return string(out)
+}
+
+// ruleKeys returns a key identifying each rule within its group. The key is
+// the rule name, suffixed with its occurrence number if the name is repeated.
+func ruleKeys(rules []rulefmt.RuleNode) []string {
+	seen := map[string]int{}
+	keys := make([]string, 0, len(rules))
+	for _, r := range rules {
+		name := getRuleName(r)
+		key := name
+		if n := seen[name]; n > 0 {
+			key = fmt.Sprintf("%s (#%d)", name, n+1)
+		}
+		seen[name]++
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// UnifiedDiff returns a line-level unified diff between two texts, without
+// file headers. An empty string is returned if both texts are equal.
+func UnifiedDiff(before, after string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:       splitLines(before),
+		B:       splitLines(after),
+		Context: diffContextLines,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return difflib.SplitLines(s)
+}
@@ -0,0 +1,178 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestDiffGroups(t *testing.T) {
+	original := rwrulefmt.RuleGroup{
+		RuleGroup: rulefmt.RuleGroup{
+			Name:     "example_group",
+			Interval: model.Duration(time.Minute),
+			Rules: []rulefmt.RuleNode{
+				{
+					Alert:  yaml.Node{Value: "HighErrorRate"},
+					Expr:   yaml.Node{Value: "job:errors:rate5m > 0.5"},
+					For:    model.Duration(5 * time.Minute),
+					Labels: map[string]string{"severity": "page"},
+				},
+				{
+					Record: yaml.Node{Value: "job:errors:rate5m"},
+					Expr:   yaml.Node{Value: "sum by (job) (rate(errors_total[5m]))"},
+				},
+				{
+					Record: yaml.Node{Value: "job:requests:rate5m"},
+					Expr:   yaml.Node{Value: "sum by (job) (rate(requests_total[5m]))"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		new            rwrulefmt.RuleGroup
+		expectedFields []FieldDiff
+		expectedRules  []RuleDiff
+	}{
+		{
+			name: "identical groups",
+			new:  original,
+		},
+		{
+			name: "threshold change",
+			new: func() rwrulefmt.RuleGroup {
+				g := copyGroup(original)
+				g.Rules[0].Expr = yaml.Node{Value: "job:errors:rate5m > 0.6"}
+				return g
+			}(),
+			expectedRules: []RuleDiff{{
+				Name:  "HighErrorRate",
+				Kind:  AlertingRuleKind,
+				State: Updated,
+				Diff: `@@ -1,5 +1,5 @@
+ alert: HighErrorRate
+-expr: job:errors:rate5m > 0.5
++expr: job:errors:rate5m > 0.6
+ for: 5m
+ labels:
+     severity: page
+`,
+			}},
+		},
+		{
+			name: "interval change, rule created and deleted",
+			new: func() rwrulefmt.RuleGroup {
+				g := copyGroup(original)
+				g.Interval = model.Duration(2 * time.Minute)
+				g.Rules[2] = rulefmt.RuleNode{
+					Record: yaml.Node{Value: "job:requests:rate1m"},
+					Expr:   yaml.Node{Value: "sum by (job) (rate(requests_total[1m]))"},
+				}
+				return g
+			}(),
+			expectedFields: []FieldDiff{{
+				Field: "interval",
+				Diff: `@@ -1 +1 @@
+-interval: 1m
++interval: 2m
+`,
+			}},
+			expectedRules: []RuleDiff{
+				{
+					Name:  "job:requests:rate1m",
+					Kind:  RecordingRuleKind,
+					State: Created,
+					Diff: `@@ -0,0 +1,2 @@
++record: job:requests:rate1m
++expr: sum by (job) (rate(requests_total[1m]))
+`,
+				},
+				{
+					Name:  "job:requests:rate5m",
+					Kind:  RecordingRuleKind,
+					State: Deleted,
+					Diff: `@@ -1,2 +0,0 @@
+-record: job:requests:rate5m
+-expr: sum by (job) (rate(requests_total[5m]))
+`,
+				},
+			},
+		},
+		{
+			name: "limit and source tenants change",
+			new: func() rwrulefmt.RuleGroup {
+				g := copyGroup(original)
+				g.Limit = 100
+				g.SourceTenants = []string{"tenant-b", "tenant-a"}
+				return g
+			}(),
+			expectedFields: []FieldDiff{
+				{
+					Field: "limit",
+					Diff: `@@ -1 +1 @@
+-limit: 0
++limit: 100
+`,
+				},
+				{
+					Field: "source_tenants",
+					Diff: `@@ -1 +1,3 @@
+-source_tenants: []
++source_tenants:
++    - tenant-a
++    - tenant-b
+`,
+				},
+			},
+		},
+		{
+			name: "rules reordered",
+			new: func() rwrulefmt.RuleGroup {
+				g := copyGroup(original)
+				g.Rules[0], g.Rules[1] = g.Rules[1], g.Rules[0]
+				return g
+			}(),
+			expectedFields: []FieldDiff{{
+				Field: "rule order",
+				Diff: `@@ -1,3 +1,3 @@
++job:errors:rate5m
+ HighErrorRate
+-job:errors:rate5m
+ job:requests:rate5m
+`,
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := UpdatedRuleGroup{Original: original, New: tt.new}.Diff()
+			require.Equal(t, tt.expectedFields, diff.Fields)
+			require.Equal(t, tt.expectedRules, diff.Rules)
+		})
+	}
+}
+
+func TestRuleKeys(t *testing.T) {
+	keys := ruleKeys([]rulefmt.RuleNode{
+		{Record: yaml.Node{Value: "up:sum"}},
+		{Alert: yaml.Node{Value: "Down"}},
+		{Record: yaml.Node{Value: "up:sum"}},
+	})
+	require.Equal(t, []string{"up:sum", "Down", "up:sum (#2)"}, keys)
+}
+
+func copyGroup(g rwrulefmt.RuleGroup) rwrulefmt.RuleGroup {
+	rules := make([]rulefmt.RuleNode, len(g.Rules))
+	copy(rules, g.Rules)
+	g.Rules = rules
+	return g
+}
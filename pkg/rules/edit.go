@@ -0,0 +1,468 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// defaultIndent is the indentation of the YAML documents written without an
+// indentation to keep.
+const defaultIndent = 4
+
+// UpdateRuleFile updates a rule file of the cortextool format with the
+// namespaces parsed from it. The expressions and recording rule names that
+// changed are replaced in the content of the file, so everything else, from
+// the comments and the order of the keys to the indentation and the style of
+// the values, is kept. Rule groups that no longer match the file, such as
+// groups with rules added or removed, are replaced as a whole, which encodes
+// the file again. The file is returned unchanged if no value changed.
+// Namespaces of rendered rule files are written as is, as the file holds the
+// templates they were rendered from.
+func UpdateRuleFile(content []byte, nss []RuleNamespace) ([]byte, error) {
+	for _, ns := range nss {
+		if ns.Source != nil {
+			return nil, fmt.Errorf("namespace %s was parsed from a PrometheusRule resource", ns.Namespace)
+		}
+		if ns.rendered {
+			sorted := append([]RuleNamespace{}, nss...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i].document < sorted[j].document })
+			return marshalNamespaces(sorted)
+		}
+	}
+
+	docs, err := decodeDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var e fileEditor
+	for _, ns := range nss {
+		if ns.document >= len(docs) || len(docs[ns.document].Content) == 0 {
+			return nil, fmt.Errorf("document %d not found", ns.document)
+		}
+		if err := e.setRuleGroups(docs[ns.document].Content[0], ns); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.write(content, docs)
+}
+
+// fileEditor records the changes made to the YAML documents of a file, so they
+// can be written to the content of the file.
+type fileEditor struct {
+	edits []scalarEdit
+	// reencode is set once nodes are added or replaced, which can only be
+	// written by encoding the documents again.
+	reencode bool
+}
+
+// scalarEdit is a change of the value of a scalar of a mapping.
+type scalarEdit struct {
+	key, node *yaml.Node
+	// style and original are the style and value of the scalar in the file.
+	style    yaml.Style
+	original string
+	value    string
+}
+
+// write returns the content of the file with the changes of the editor. The
+// changed scalars are replaced in the content, unless nodes were added or
+// replaced.
+func (e *fileEditor) write(content []byte, docs []*yaml.Node) ([]byte, error) {
+	if !e.reencode && len(e.edits) == 0 {
+		return content, nil
+	}
+	if !e.reencode {
+		updated, err := spliceScalars(content, e.edits)
+		if err == nil {
+			return updated, nil
+		}
+		log.WithError(err).Debugln("unable to replace the values in the file, encoding it again")
+	}
+	return encodeDocuments(docs)
+}
+
+// setRuleGroups sets the rule groups of a namespace as the groups of a mapping
+// node. The groups matching the ones of the node are updated in place, others
+// are replaced.
+func (e *fileEditor) setRuleGroups(node *yaml.Node, ns RuleNamespace) error {
+	existing := mappingValue(node, "groups")
+	if existing != nil && !ns.rendered && existing.Kind == yaml.SequenceNode && len(existing.Content) == len(ns.Groups) {
+		for i, g := range ns.Groups {
+			if ruleGroupMatches(existing.Content[i], g) {
+				e.updateRuleGroup(existing.Content[i], g)
+				continue
+			}
+			if err := existing.Content[i].Encode(g); err != nil {
+				return err
+			}
+			e.reencode = true
+		}
+		return nil
+	}
+
+	var value yaml.Node
+	if err := value.Encode(ns.Groups); err != nil {
+		return err
+	}
+	e.reencode = true
+	if existing != nil {
+		*existing = value
+		return nil
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "groups"}, &value)
+	return nil
+}
+
+// ruleGroupMatches reports whether a group node has the name and number of
+// rules of a rule group.
+func ruleGroupMatches(node *yaml.Node, g rwrulefmt.RuleGroup) bool {
+	name := mappingValue(node, "name")
+	if name == nil || name.Value != g.Name {
+		return false
+	}
+
+	rules := mappingValue(node, "rules")
+	if rules == nil {
+		return len(g.Rules) == 0
+	}
+	if rules.Kind != yaml.SequenceNode || len(rules.Content) != len(g.Rules) {
+		return false
+	}
+	for _, r := range rules.Content {
+		if r.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// updateRuleGroup updates the expressions and recording rule names of a group
+// node matching the rule group.
+func (e *fileEditor) updateRuleGroup(node *yaml.Node, g rwrulefmt.RuleGroup) {
+	rules := mappingValue(node, "rules")
+	for i, r := range g.Rules {
+		if r.Record.Value != "" {
+			e.setMappingScalar(rules.Content[i], "record", r.Record.Value)
+		}
+		e.setMappingScalar(rules.Content[i], "expr", r.Expr.Value)
+	}
+}
+
+// setMappingScalar sets the value of a key of a mapping node, if it changed.
+func (e *fileEditor) setMappingScalar(node *yaml.Node, key, value string) {
+	k, existing := mappingEntry(node, key)
+	if existing == nil {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value},
+		)
+		e.reencode = true
+		return
+	}
+	if existing.Kind != yaml.ScalarNode {
+		*existing = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+		e.reencode = true
+		return
+	}
+
+	// Block scalars keep their final line break, so their chomping indicator
+	// doesn't change.
+	block := existing.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0
+	if block && strings.HasSuffix(existing.Value, "\n") && !strings.HasSuffix(value, "\n") {
+		value += "\n"
+	}
+	if existing.Value == value {
+		return
+	}
+
+	e.edits = append(e.edits, scalarEdit{key: k, node: existing, style: existing.Style, original: existing.Value, value: value})
+	existing.Value = value
+	existing.Tag = "!!str"
+	if !block && strings.Contains(strings.TrimSuffix(value, "\n"), "\n") {
+		existing.Style = yaml.LiteralStyle
+	}
+}
+
+// spliceScalars replaces the source of the edited scalars in the content, from
+// the positions of their nodes.
+func spliceScalars(content []byte, edits []scalarEdit) ([]byte, error) {
+	lines := []int{0}
+	for i, c := range content {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	replacements := make([]replacement, 0, len(edits))
+	for _, edit := range edits {
+		start, end, indent, err := scalarSource(content, lines, edit)
+		if err != nil {
+			return nil, err
+		}
+		replacements = append(replacements, replacement{start, end, renderScalar(edit, indent)})
+	}
+
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+	updated := append([]byte{}, content...)
+	for i, r := range replacements {
+		if i > 0 && r.end > replacements[i-1].start {
+			return nil, errors.New("overlapping values")
+		}
+		updated = append(updated[:r.start], append([]byte(r.text), updated[r.end:]...)...)
+	}
+	return updated, nil
+}
+
+// scalarSource returns the byte offsets of the source of an edited scalar in
+// the content. The source of block scalars is their content, without the
+// header holding their indicators, and their indentation is returned too.
+func scalarSource(content []byte, lines []int, edit scalarEdit) (int, int, int, error) {
+	start, err := nodeOffset(content, lines, edit.node)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	lineEnd := func(offset int) int {
+		if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+			return offset + i
+		}
+		return len(content)
+	}
+
+	switch {
+	case edit.style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(content); i++ {
+			switch content[i] {
+			case '\\':
+				i++
+			case '"':
+				return start, i + 1, 0, nil
+			}
+		}
+
+	case edit.style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(content); i++ {
+			if content[i] != '\'' {
+				continue
+			}
+			if i+1 < len(content) && content[i+1] == '\'' {
+				i++
+				continue
+			}
+			return start, i + 1, 0, nil
+		}
+
+	case edit.style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		header := string(content[start:lineEnd(start)])
+		if i := strings.Index(header, "#"); i >= 0 {
+			header = header[:i]
+		}
+		if strings.ContainsAny(header, "123456789") {
+			return 0, 0, 0, errors.New("block scalar with an indentation indicator")
+		}
+
+		// The content of the block is made of the lines indented more than
+		// its key, up to the last one that isn't blank.
+		var (
+			first, last = -1, -1
+			indent      int
+		)
+		for l := edit.node.Line; l < len(lines); l++ {
+			line := string(content[lines[l]:lineEnd(lines[l])])
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := len(line) - len(strings.TrimLeft(line, " "))
+			if first == -1 {
+				if n < edit.key.Column {
+					break
+				}
+				first, indent = lines[l], n
+			} else if n < indent {
+				break
+			}
+			last = lineEnd(lines[l])
+		}
+		if first == -1 {
+			return 0, 0, 0, errors.New("empty block scalar")
+		}
+		return first, last, indent, nil
+
+	default:
+		// Plain scalars end before a comment, and may be folded over several
+		// lines.
+		end := start
+		var source []string
+		for l := edit.node.Line - 1; l < len(lines) && len(source) < 100; l++ {
+			from := lines[l]
+			if l == edit.node.Line-1 {
+				from = start
+			}
+			line := string(content[from:lineEnd(from)])
+			if i := strings.Index(line, " #"); i >= 0 {
+				line = line[:i]
+			}
+			text := strings.TrimSpace(line)
+			source = append(source, text)
+			end = from + strings.Index(line, text) + len(text)
+			if strings.Join(source, " ") == edit.original {
+				return start, end, 0, nil
+			}
+		}
+	}
+
+	return 0, 0, 0, fmt.Errorf("unable to find the value at line %d", edit.node.Line)
+}
+
+// nodeOffset returns the byte offset of a node in the content.
+func nodeOffset(content []byte, lines []int, node *yaml.Node) (int, error) {
+	if node.Line < 1 || node.Line > len(lines) {
+		return 0, fmt.Errorf("line %d not found", node.Line)
+	}
+	offset := lines[node.Line-1]
+	// Columns count characters.
+	for i := 1; i < node.Column; i++ {
+		if offset >= len(content) || content[offset] == '\n' {
+			return 0, fmt.Errorf("column %d of line %d not found", node.Column, node.Line)
+		}
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+	return offset, nil
+}
+
+// renderScalar returns the source of the new value of an edited scalar, in
+// its style. The content of block scalars is indented by indent, values of
+// other scalars spanning several lines are written as literal blocks.
+func renderScalar(edit scalarEdit, indent int) string {
+	block := edit.style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0
+	value := strings.TrimSuffix(edit.value, "\n")
+
+	if !block && !strings.Contains(value, "\n") {
+		out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: edit.style, Value: edit.value})
+		if err == nil && !strings.Contains(strings.TrimSuffix(string(out), "\n"), "\n") {
+			return strings.TrimSuffix(string(out), "\n")
+		}
+	}
+
+	if !block {
+		indent = edit.key.Column - 1 + 2
+	}
+	prefix := strings.Repeat(" ", indent)
+
+	lines := strings.Split(value, "\n")
+	if edit.style&yaml.FoldedStyle != 0 {
+		// Line breaks of folded scalars are written as empty lines.
+		lines = strings.Split(strings.ReplaceAll(value, "\n", "\n\n"), "\n")
+	}
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+
+	text := strings.Join(lines, "\n")
+	if block {
+		return text
+	}
+	chomping := "|-"
+	if strings.HasSuffix(edit.value, "\n") {
+		chomping = "|"
+	}
+	return chomping + "\n" + text
+}
+
+// decodeDocuments decodes the YAML documents of a file.
+func decodeDocuments(content []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &node)
+	}
+}
+
+// encodeDocuments encodes YAML documents, with the indentation they were
+// written with.
+func encodeDocuments(docs []*yaml.Node) ([]byte, error) {
+	indent := defaultIndent
+	for _, doc := range docs {
+		if i := nodeIndent(doc); i > 0 {
+			indent = i
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalNamespaces encodes namespaces as a set of YAML documents.
+func marshalNamespaces(nss []RuleNamespace) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(defaultIndent)
+	for _, ns := range nss {
+		if err := encoder.Encode(ns); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// nodeIndent returns the indentation of the first block collection nested in
+// a mapping of a node, or 0 if there is none.
+func nodeIndent(node *yaml.Node) int {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind != yaml.MappingNode && value.Kind != yaml.SequenceNode {
+				continue
+			}
+			if value.Style&yaml.FlowStyle == 0 && value.Line > key.Line && value.Column > key.Column {
+				return value.Column - key.Column
+			}
+		}
+	}
+	for _, c := range node.Content {
+		if i := nodeIndent(c); i > 0 {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,161 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestUpdateRuleFile(t *testing.T) {
+	content := []byte(`# Rules of the API team.
+namespace: api
+groups:
+  # Request rates.
+  - name: requests
+    rules:
+      - record: requests_rate # per job
+        expr: sum by(job)(rate(http_requests_total[5m]))
+      - expr: |
+          sum by (job) (
+            rate(http_errors_total[5m])
+          )
+        record: job:http_errors:rate5m
+      - alert: HighErrorRate
+        expr: >-
+          job:http_errors:rate5m / requests_rate > 0.05
+        for: 10m
+---
+namespace: other
+groups:
+  - name: other
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+`)
+
+	nss, errs := ParseBytes(content)
+	require.Empty(t, errs)
+	require.Len(t, nss, 2)
+
+	// The file is kept as is if nothing changed.
+	updated, err := UpdateRuleFile(content, nss)
+	require.NoError(t, err)
+	require.Equal(t, string(content), string(updated))
+
+	rules := nss[0].Groups[0].Rules
+	rules[0].Record.Value = "job:http_requests:rate5m"
+	rules[0].Expr.Value = "sum by (job) (rate(http_requests_total[5m]))"
+	rules[1].Expr.Value = "sum by (job) (rate(http_errors_total[5m]))"
+	rules[2].Expr.Value = "job:http_errors:rate5m / job:http_requests:rate5m > 0.05"
+
+	updated, err = UpdateRuleFile(content, nss)
+	require.NoError(t, err)
+	require.Equal(t, `# Rules of the API team.
+namespace: api
+groups:
+  # Request rates.
+  - name: requests
+    rules:
+      - record: job:http_requests:rate5m # per job
+        expr: sum by (job) (rate(http_requests_total[5m]))
+      - expr: |
+          sum by (job) (rate(http_errors_total[5m]))
+        record: job:http_errors:rate5m
+      - alert: HighErrorRate
+        expr: >-
+          job:http_errors:rate5m / job:http_requests:rate5m > 0.05
+        for: 10m
+---
+namespace: other
+groups:
+  - name: other
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+`, string(updated))
+
+	// Groups that no longer match the file are replaced.
+	nss[1].Groups[0].Rules = append(nss[1].Groups[0].Rules, rulefmt.RuleNode{
+		Record: yaml.Node{Kind: yaml.ScalarNode, Value: "job:up:max"},
+		Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: "max by (job) (up)"},
+	})
+	updated, err = UpdateRuleFile(content, nss)
+	require.NoError(t, err)
+
+	reparsed, errs := ParseBytes(updated)
+	require.Empty(t, errs)
+	require.Len(t, reparsed, 2)
+	require.Equal(t, "job:http_requests:rate5m", reparsed[0].Groups[0].Rules[0].Record.Value)
+	require.Len(t, reparsed[1].Groups[0].Rules, 2)
+	require.Equal(t, "max by (job) (up)", reparsed[1].Groups[0].Rules[1].Expr.Value)
+	require.Contains(t, string(updated), "# Request rates.")
+}
+
+func TestUpdateRuleFile_ZeroIndentedSequences(t *testing.T) {
+	content := []byte(`namespace: api
+groups:
+- name: requests
+  rules:
+  - record: requests_rate
+    expr: "sum by(job)(rate(http_requests_total[5m]))"
+
+  - record: job:http_errors:rate5m   # errors
+    expr: sum by(job)
+      (rate(http_errors_total[5m]))
+  - alert: HighErrorRate
+    expr: |-
+      job:http_errors:rate5m
+        / requests_rate > 0.05
+
+    for: 10m
+`)
+
+	nss, errs := ParseBytes(content)
+	require.Empty(t, errs)
+
+	rules := nss[0].Groups[0].Rules
+	rules[0].Record.Value = "job:http_requests:rate5m"
+	rules[0].Expr.Value = "sum by (job) (rate(http_requests_total[5m]))"
+	rules[1].Expr.Value = "sum by (job) (rate(http_errors_total[5m]))"
+	rules[2].Expr.Value = "job:http_errors:rate5m / job:http_requests:rate5m > 0.05"
+
+	updated, err := UpdateRuleFile(content, nss)
+	require.NoError(t, err)
+	require.Equal(t, `namespace: api
+groups:
+- name: requests
+  rules:
+  - record: job:http_requests:rate5m
+    expr: "sum by (job) (rate(http_requests_total[5m]))"
+
+  - record: job:http_errors:rate5m   # errors
+    expr: sum by (job) (rate(http_errors_total[5m]))
+  - alert: HighErrorRate
+    expr: |-
+      job:http_errors:rate5m / job:http_requests:rate5m > 0.05
+
+    for: 10m
+`, string(updated))
+}
+
+func TestUpdateRuleFile_Rendered(t *testing.T) {
+	nss, err := ParseFilesWithOptions(CortexBackend, []string{"testdata/templated/rules.yaml"}, ParseOptions{
+		Values: map[string]interface{}{"cluster": "prod", "errorThreshold": 5},
+	})
+	require.NoError(t, err)
+
+	var rendered []RuleNamespace
+	for _, ns := range nss {
+		rendered = append(rendered, ns)
+	}
+
+	// The rendered rules are written instead of the templates of the file.
+	updated, err := UpdateRuleFile([]byte("[[ invalid"), rendered)
+	require.NoError(t, err)
+
+	reparsed, errs := ParseBytes(updated)
+	require.Empty(t, errs)
+	require.Equal(t, `sum(rate(http_errors_total{cluster="prod"}[5m])) > 5`, reparsed[0].Groups[0].Rules[0].Expr.Value)
+}
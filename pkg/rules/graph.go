@@ -0,0 +1,238 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Names of the dependency checks, as reported in findings.
+const (
+	EvaluationOrderCheck = "evaluation-order"
+	RuleCycleCheck       = "rule-cycle"
+	GroupCycleCheck      = "group-cycle"
+)
+
+// GraphNode is a rule of a dependency graph.
+type GraphNode struct {
+	// ID identifies the rule by its namespace, group and position in the group,
+	// as rule names are not unique.
+	ID        string `json:"id"`
+	File      string `json:"file"`
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+	Index     int    `json:"index"`
+	Rule      string `json:"rule"`
+	Recording bool   `json:"recording"`
+}
+
+// GraphEdge is a rule using the output of a recording rule.
+type GraphEdge struct {
+	// From is the ID of the rule using the metric.
+	From string `json:"from"`
+	// To is the ID of the recording rule recording the metric.
+	To     string `json:"to"`
+	Metric string `json:"metric"`
+}
+
+// DependencyGraph is the graph of the rules using the output of recording
+// rules.
+type DependencyGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// BuildDependencyGraph returns the dependency graph of the rules of the
+// namespaces. A rule depends on every recording rule recording a metric name
+// used by one of its selectors. Nodes are sorted by namespace and keep the
+// order of the groups and rules within a namespace.
+func BuildDependencyGraph(namespaces map[string]RuleNamespace) *DependencyGraph {
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	graph := &DependencyGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	recorders := map[string][]string{}
+	for _, name := range names {
+		ns := namespaces[name]
+		for _, g := range ns.Groups {
+			for i, r := range g.Rules {
+				node := GraphNode{
+					ID:        fmt.Sprintf("%s/%s/%d", name, g.Name, i),
+					File:      ns.Filepath,
+					Namespace: name,
+					Group:     g.Name,
+					Index:     i,
+					Rule:      getRuleName(r),
+					Recording: r.Record.Value != "",
+				}
+				graph.Nodes = append(graph.Nodes, node)
+				if node.Recording {
+					recorders[r.Record.Value] = append(recorders[r.Record.Value], node.ID)
+				}
+			}
+		}
+	}
+
+	recorded := make(map[string]struct{}, len(recorders))
+	for metric := range recorders {
+		recorded[metric] = struct{}{}
+	}
+
+	i := 0
+	for _, name := range names {
+		for _, g := range namespaces[name].Groups {
+			for _, r := range g.Rules {
+				from := graph.Nodes[i].ID
+				i++
+				for _, metric := range recordedDependencies(r.Expr.Value, recorded) {
+					for _, to := range recorders[metric] {
+						graph.Edges = append(graph.Edges, GraphEdge{From: from, To: to, Metric: metric})
+					}
+				}
+			}
+		}
+	}
+
+	return graph
+}
+
+// Check returns the evaluation hazards of the graph:
+//   - rules using the output of a recording rule evaluated after them in the
+//     same group, which only see the result of its previous evaluation,
+//   - cycles of rules using the output of each other,
+//   - cycles of groups using the output of each other. Groups are evaluated
+//     independently, so the data they exchange is always one evaluation late.
+func (g *DependencyGraph) Check() []Finding {
+	nodes := make(map[string]GraphNode, len(g.Nodes))
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+
+	var findings []Finding
+	newFinding := func(n GraphNode, check, format string, args ...interface{}) Finding {
+		return Finding{
+			File:      n.File,
+			Namespace: n.Namespace,
+			Group:     n.Group,
+			Rule:      n.Rule,
+			Check:     check,
+			Message:   fmt.Sprintf(format, args...),
+		}
+	}
+
+	ruleEdges := map[string][]string{}
+	for _, e := range g.Edges {
+		from, to := nodes[e.From], nodes[e.To]
+		ruleEdges[e.From] = append(ruleEdges[e.From], e.To)
+
+		if from.Namespace == to.Namespace && from.Group == to.Group && to.Index > from.Index {
+			findings = append(findings, newFinding(from, EvaluationOrderCheck, "uses %s which is recorded later in the group, so it gets the result of its previous evaluation", e.Metric))
+		}
+	}
+
+	ids := make([]string, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	for _, cycle := range findCycles(ids, ruleEdges) {
+		rules := make([]string, 0, len(cycle))
+		for _, id := range cycle {
+			rules = append(rules, nodes[id].Rule)
+		}
+		findings = append(findings, newFinding(nodes[cycle[0]], RuleCycleCheck, "rules depend on each other: %s", strings.Join(rules, ", ")))
+	}
+
+	groupID := func(n GraphNode) string { return n.Namespace + "/" + n.Group }
+	var groupIDs []string
+	firstNode := map[string]GraphNode{}
+	for _, n := range g.Nodes {
+		if _, ok := firstNode[groupID(n)]; !ok {
+			firstNode[groupID(n)] = n
+			groupIDs = append(groupIDs, groupID(n))
+		}
+	}
+	groupEdges := map[string][]string{}
+	for _, e := range g.Edges {
+		from, to := groupID(nodes[e.From]), groupID(nodes[e.To])
+		if from != to && !contains(groupEdges[from], to) {
+			groupEdges[from] = append(groupEdges[from], to)
+		}
+	}
+	for _, cycle := range findCycles(groupIDs, groupEdges) {
+		n := firstNode[cycle[0]]
+		n.Rule = ""
+		findings = append(findings, newFinding(n, GroupCycleCheck, "rule groups depend on each other: %s", strings.Join(cycle, ", ")))
+	}
+
+	return findings
+}
+
+// findCycles returns the strongly connected components of a graph with more
+// than one vertex or a vertex depending on itself, with Tarjan's algorithm.
+// Vertices keep the order of the given ids within a cycle, and cycles are
+// sorted by their first vertex in this order.
+func findCycles(ids []string, edges map[string][]string) [][]string {
+	order := make(map[string]int, len(ids))
+	for i, id := range ids {
+		order[id] = i
+	}
+
+	var (
+		index   int
+		stack   []string
+		onStack = map[string]bool{}
+		indexes = map[string]int{}
+		lowlink = map[string]int{}
+		cycles  [][]string
+		connect func(v string)
+	)
+	connect = func(v string) {
+		indexes[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if _, visited := indexes[w]; !visited {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && indexes[w] < lowlink[v] {
+				lowlink[v] = indexes[w]
+			}
+		}
+
+		if lowlink[v] != indexes[v] {
+			return
+		}
+
+		var component []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 || contains(edges[v], v) {
+			sort.Slice(component, func(i, j int) bool { return order[component[i]] < order[component[j]] })
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, id := range ids {
+		if _, visited := indexes[id]; !visited {
+			connect(id)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool { return order[cycles[i][0]] < order[cycles[j][0]] })
+	return cycles
+}
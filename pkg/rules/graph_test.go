@@ -0,0 +1,76 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	record := func(name, expr string) rulefmt.RuleNode {
+		return rulefmt.RuleNode{Record: yaml.Node{Value: name}, Expr: yaml.Node{Value: expr}}
+	}
+	alert := func(name, expr string) rulefmt.RuleNode {
+		return rulefmt.RuleNode{Alert: yaml.Node{Value: name}, Expr: yaml.Node{Value: expr}}
+	}
+	group := func(name string, rules ...rulefmt.RuleNode) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: name, Rules: rules}}
+	}
+
+	namespaces := map[string]RuleNamespace{
+		"b": {Namespace: "b", Filepath: "b.yaml", Groups: []rwrulefmt.RuleGroup{
+			group("ordered",
+				record("job:up:sum", "sum by (job) (up)"),
+				alert("JobDown", `job:up:sum == 0 or {__name__="job:up:max"} == 0`),
+			),
+			group("unordered",
+				alert("JobDown", "job:up:max == 0"),
+				record("job:up:max", "max by (job) (up)"),
+			),
+		}},
+		"a": {Namespace: "a", Filepath: "a.yaml", Groups: []rwrulefmt.RuleGroup{
+			group("first", record("x:a:sum", "sum(x:b:sum)")),
+			group("second",
+				record("x:b:sum", "sum(x:a:sum)"),
+				record("x:c:sum", "sum(x:c:sum offset 1m)"),
+			),
+		}},
+	}
+
+	graph := BuildDependencyGraph(namespaces)
+	require.Equal(t, []GraphNode{
+		{ID: "a/first/0", File: "a.yaml", Namespace: "a", Group: "first", Index: 0, Rule: "x:a:sum", Recording: true},
+		{ID: "a/second/0", File: "a.yaml", Namespace: "a", Group: "second", Index: 0, Rule: "x:b:sum", Recording: true},
+		{ID: "a/second/1", File: "a.yaml", Namespace: "a", Group: "second", Index: 1, Rule: "x:c:sum", Recording: true},
+		{ID: "b/ordered/0", File: "b.yaml", Namespace: "b", Group: "ordered", Index: 0, Rule: "job:up:sum", Recording: true},
+		{ID: "b/ordered/1", File: "b.yaml", Namespace: "b", Group: "ordered", Index: 1, Rule: "JobDown"},
+		{ID: "b/unordered/0", File: "b.yaml", Namespace: "b", Group: "unordered", Index: 0, Rule: "JobDown"},
+		{ID: "b/unordered/1", File: "b.yaml", Namespace: "b", Group: "unordered", Index: 1, Rule: "job:up:max", Recording: true},
+	}, graph.Nodes)
+	require.Equal(t, []GraphEdge{
+		{From: "a/first/0", To: "a/second/0", Metric: "x:b:sum"},
+		{From: "a/second/0", To: "a/first/0", Metric: "x:a:sum"},
+		{From: "a/second/1", To: "a/second/1", Metric: "x:c:sum"},
+		{From: "b/ordered/1", To: "b/unordered/1", Metric: "job:up:max"},
+		{From: "b/ordered/1", To: "b/ordered/0", Metric: "job:up:sum"},
+		{From: "b/unordered/0", To: "b/unordered/1", Metric: "job:up:max"},
+	}, graph.Edges)
+
+	require.Equal(t, []Finding{
+		{File: "b.yaml", Namespace: "b", Group: "unordered", Rule: "JobDown", Check: EvaluationOrderCheck, Message: "uses job:up:max which is recorded later in the group, so it gets the result of its previous evaluation"},
+		{File: "a.yaml", Namespace: "a", Group: "first", Rule: "x:a:sum", Check: RuleCycleCheck, Message: "rules depend on each other: x:a:sum, x:b:sum"},
+		{File: "a.yaml", Namespace: "a", Group: "second", Rule: "x:c:sum", Check: RuleCycleCheck, Message: "rules depend on each other: x:c:sum"},
+		{File: "a.yaml", Namespace: "a", Group: "first", Check: GroupCycleCheck, Message: "rule groups depend on each other: a/first, a/second"},
+	}, graph.Check())
+}
+
+func TestDependencyGraph_Empty(t *testing.T) {
+	graph := BuildDependencyGraph(nil)
+	require.Empty(t, graph.Nodes)
+	require.Empty(t, graph.Edges)
+	require.Empty(t, graph.Check())
+}
@@ -0,0 +1,94 @@
+package rules
+
+import (
+	"time"
+)
+
+// Health of the rules, as reported by the ruler.
+const (
+	HealthOK      = "ok"
+	HealthError   = "err"
+	HealthUnknown = "unknown"
+)
+
+// AlertStateFiring is the state of firing alerts.
+const AlertStateFiring = "firing"
+
+// RuleHealth is the evaluation state of a rule.
+type RuleHealth struct {
+	Rule string `json:"rule" yaml:"rule"`
+	// Type is either alerting or recording.
+	Type   string `json:"type" yaml:"type"`
+	Health string `json:"health" yaml:"health"`
+	// State is the state of an alerting rule: inactive, pending or firing.
+	State             string    `json:"state,omitempty" yaml:"state,omitempty"`
+	LastError         string    `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	LastEvaluation    time.Time `json:"last_evaluation" yaml:"last_evaluation"`
+	EvaluationSeconds float64   `json:"evaluation_seconds" yaml:"evaluation_seconds"`
+	FiringAlerts      int       `json:"firing_alerts" yaml:"firing_alerts"`
+}
+
+// GroupHealth is the evaluation state of a rule group and its rules.
+type GroupHealth struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Group     string `json:"group" yaml:"group"`
+	// Health is err if any rule failed its last evaluation, unknown if any
+	// rule was not evaluated yet, and ok otherwise.
+	Health string `json:"health" yaml:"health"`
+	// LastError is the error of the first rule that failed its last evaluation.
+	LastError         string       `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	LastEvaluation    time.Time    `json:"last_evaluation" yaml:"last_evaluation"`
+	EvaluationSeconds float64      `json:"evaluation_seconds" yaml:"evaluation_seconds"`
+	FiringAlerts      int          `json:"firing_alerts" yaml:"firing_alerts"`
+	Rules             []RuleHealth `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// NewGroupHealth returns the evaluation state of a rule group, summarizing the
+// state of its rules.
+func NewGroupHealth(namespace, group string, lastEvaluation time.Time, evaluationSeconds float64, rules []RuleHealth) GroupHealth {
+	g := GroupHealth{
+		Namespace:         namespace,
+		Group:             group,
+		Health:            HealthOK,
+		LastEvaluation:    lastEvaluation,
+		EvaluationSeconds: evaluationSeconds,
+		Rules:             rules,
+	}
+
+	for _, r := range rules {
+		g.FiringAlerts += r.FiringAlerts
+
+		switch {
+		case r.Health == HealthError:
+			if g.Health != HealthError {
+				g.LastError = r.LastError
+			}
+			g.Health = HealthError
+		case r.Health != HealthOK && g.Health == HealthOK:
+			g.Health = HealthUnknown
+		}
+	}
+
+	return g
+}
+
+// Unhealthy returns the groups having rules that are not healthy, with only
+// their unhealthy rules.
+func Unhealthy(groups []GroupHealth) []GroupHealth {
+	var unhealthy []GroupHealth
+	for _, g := range groups {
+		if g.Health == HealthOK {
+			continue
+		}
+
+		var rules []RuleHealth
+		for _, r := range g.Rules {
+			if r.Health != HealthOK {
+				rules = append(rules, r)
+			}
+		}
+		g.Rules = rules
+		unhealthy = append(unhealthy, g)
+	}
+	return unhealthy
+}
@@ -0,0 +1,75 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGroupHealth(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		rules     []RuleHealth
+		health    string
+		lastError string
+		firing    int
+	}{
+		{
+			name:   "no rules",
+			health: HealthOK,
+		},
+		{
+			name:   "healthy rules",
+			rules:  []RuleHealth{{Rule: "a", Health: HealthOK, FiringAlerts: 1}, {Rule: "b", Health: HealthOK, FiringAlerts: 2}},
+			health: HealthOK,
+			firing: 3,
+		},
+		{
+			name:   "rule not evaluated yet",
+			rules:  []RuleHealth{{Rule: "a", Health: HealthOK}, {Rule: "b", Health: HealthUnknown}},
+			health: HealthUnknown,
+		},
+		{
+			name: "failing rules",
+			rules: []RuleHealth{
+				{Rule: "a", Health: HealthUnknown},
+				{Rule: "b", Health: HealthError, LastError: "first"},
+				{Rule: "c", Health: HealthError, LastError: "second"},
+			},
+			health:    HealthError,
+			lastError: "first",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGroupHealth("ns", "group", now, 0.1, tt.rules)
+			assert.Equal(t, "ns", g.Namespace)
+			assert.Equal(t, "group", g.Group)
+			assert.Equal(t, now, g.LastEvaluation)
+			assert.Equal(t, tt.health, g.Health)
+			assert.Equal(t, tt.lastError, g.LastError)
+			assert.Equal(t, tt.firing, g.FiringAlerts)
+		})
+	}
+}
+
+func TestUnhealthy(t *testing.T) {
+	groups := []GroupHealth{
+		NewGroupHealth("ns", "healthy", time.Time{}, 0, []RuleHealth{{Rule: "a", Health: HealthOK}}),
+		NewGroupHealth("ns", "failing", time.Time{}, 0, []RuleHealth{{Rule: "b", Health: HealthOK}, {Rule: "c", Health: HealthError}}),
+		NewGroupHealth("ns", "pending", time.Time{}, 0, []RuleHealth{{Rule: "d", Health: HealthUnknown}}),
+	}
+
+	unhealthy := Unhealthy(groups)
+	assert.Equal(t, []GroupHealth{
+		{Namespace: "ns", Group: "failing", Health: HealthError, Rules: []RuleHealth{{Rule: "c", Health: HealthError}}},
+		{Namespace: "ns", Group: "pending", Health: HealthUnknown, Rules: []RuleHealth{{Rule: "d", Health: HealthUnknown}}},
+	}, unhealthy)
+	assert.Len(t, groups[1].Rules, 2, "the groups must not be modified")
+
+	assert.Nil(t, Unhealthy(groups[:1]))
+}
@@ -0,0 +1,323 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// RecordingRuleRename is a recording rule renamed by FixRecordingRules.
+type RecordingRuleRename struct {
+	Namespace string
+	Group     string
+	From      string
+	To        string
+}
+
+// RecordingRuleFix is the result of FixRecordingRules.
+type RecordingRuleFix struct {
+	Renames []RecordingRuleRename
+	// Unfixed is the number of misnamed recording rules that were not renamed,
+	// because no name could be derived from their expression or the derived
+	// name conflicts with another rule.
+	Unfixed int
+	// Changes are the rules modified by the renames, the renamed rules and the
+	// rules referencing them.
+	Changes []ExprChange
+}
+
+// validRecordingRuleName reports whether a recording rule name follows the
+// level:metric:operations format. Only the metric is required unless strict.
+func validRecordingRuleName(name string, strict bool) bool {
+	reqChunks := 2
+	if strict {
+		reqChunks = 3
+	}
+	return len(strings.Split(name, ":")) >= reqChunks
+}
+
+// FixRecordingRules renames the recording rules of the namespaces whose name
+// doesn't follow the level:metric:operations format to the name derived from
+// their expression by ConventionalRecordingName, and rewrites every reference
+// to the renamed rules in all the namespaces. The expressions not referencing
+// a renamed rule, including the ones of the renamed rules, are left as
+// written. Rules are renamed by name, so every rule recording a misnamed
+// metric must derive the same name, and the derived name must not already be
+// recorded.
+func FixRecordingRules(nss map[string]RuleNamespace, strict bool) (RecordingRuleFix, error) {
+	var fix RecordingRuleFix
+
+	names := make([]string, 0, len(nss))
+	for name := range nss {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	recorded := map[string]struct{}{}
+	for _, ns := range nss {
+		for _, g := range ns.Groups {
+			for _, r := range g.Rules {
+				if r.Record.Value != "" {
+					recorded[r.Record.Value] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// Derive the names of every misnamed rule first, so conflicting names are
+	// known before any rule is renamed.
+	var misnamed []RecordingRuleRename
+	derived := map[string]map[string]struct{}{}
+	for _, name := range names {
+		ns := nss[name]
+		for _, g := range ns.Groups {
+			for _, r := range g.Rules {
+				if r.Record.Value == "" || validRecordingRuleName(r.Record.Value, strict) {
+					continue
+				}
+
+				to, err := ConventionalRecordingName(r.Expr.Value)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"namespace": ns.Namespace,
+						"ruleGroup": g.Name,
+						"rule":      r.Record.Value,
+					}).WithError(err).Warnln("unable to derive a recording rule name")
+					fix.Unfixed++
+					continue
+				}
+
+				misnamed = append(misnamed, RecordingRuleRename{Namespace: ns.Namespace, Group: g.Name, From: r.Record.Value, To: to})
+				if derived[r.Record.Value] == nil {
+					derived[r.Record.Value] = map[string]struct{}{}
+				}
+				derived[r.Record.Value][to] = struct{}{}
+			}
+		}
+	}
+
+	renames := map[string]string{}
+	targets := map[string]int{}
+	for from, tos := range derived {
+		if len(tos) != 1 {
+			continue
+		}
+		for to := range tos {
+			renames[from] = to
+			targets[to]++
+		}
+	}
+
+	for _, m := range misnamed {
+		logger := log.WithFields(log.Fields{
+			"namespace": m.Namespace,
+			"ruleGroup": m.Group,
+			"rule":      m.From,
+		})
+		to, ok := renames[m.From]
+		if !ok {
+			logger.Warnln("unable to rename recording rule, the rules recording it derive different names")
+			fix.Unfixed++
+			continue
+		}
+		if _, exists := recorded[to]; exists || targets[to] > 1 {
+			logger.Warnf("unable to rename recording rule to %s, the name conflicts with another recording rule", to)
+			fix.Unfixed++
+			continue
+		}
+		fix.Renames = append(fix.Renames, m)
+	}
+	if len(fix.Renames) == 0 {
+		return fix, nil
+	}
+
+	// Only the names of the rules that are renamed are rewritten.
+	rewriter := Rewriter{RenameMetrics: map[string]string{}}
+	for _, m := range fix.Renames {
+		rewriter.RenameMetrics[m.From] = m.To
+	}
+	for _, name := range names {
+		_, changes, err := rewriter.Rewrite(nss[name])
+		if err != nil {
+			return fix, errors.Wrapf(err, "unable to rewrite namespace %s", name)
+		}
+		fix.Changes = append(fix.Changes, changes...)
+	}
+
+	return fix, nil
+}
+
+// ConventionalRecordingName derives the name of a recording rule from its
+// expression, following the level:metric:operations format of the Prometheus
+// best practices, https://prometheus.io/docs/practices/rules/. The level is
+// made of the labels the expression aggregates by, the metric is the metric
+// selected by the expression, without the _total suffix if it is rated, and
+// the operations are the aggregations and functions applied to the metric,
+// outermost first. A sum is implied by the level and is left out of the
+// operations unless it is the only one. Divisions of two metrics are named
+// after both metrics and the operations of the dividend, prefixed by ratio.
+func ConventionalRecordingName(expr string) (string, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", err
+	}
+
+	level, known := outputLabels(e)
+	if !known || len(level) == 0 {
+		return "", errors.New("the expression doesn't aggregate by a known set of labels")
+	}
+
+	var (
+		metric string
+		ops    []string
+	)
+	if b, ok := unwrapExpr(e).(*parser.BinaryExpr); ok && b.Op == parser.DIV && b.LHS.Type() == parser.ValueTypeVector && b.RHS.Type() == parser.ValueTypeVector {
+		lhsMetric, lhsOps, err := nameOperations(b.LHS)
+		if err != nil {
+			return "", err
+		}
+		rhsMetric, _, err := nameOperations(b.RHS)
+		if err != nil {
+			return "", err
+		}
+		metric = lhsMetric + "_per_" + rhsMetric
+		ops = append([]string{"ratio"}, omitSum(lhsOps)...)
+	} else {
+		metric, ops, err = nameOperations(e)
+		if err != nil {
+			return "", err
+		}
+		ops = omitSum(ops)
+	}
+
+	if len(ops) == 0 {
+		return "", errors.New("the expression doesn't apply any operation to the metric")
+	}
+
+	return fmt.Sprintf("%s:%s:%s", strings.Join(level, "_"), metric, strings.Join(ops, "_")), nil
+}
+
+// nameOperations returns the metric selected by an expression and the
+// operations applied to it, outermost first.
+func nameOperations(expr parser.Expr) (string, []string, error) {
+	switch e := unwrapExpr(expr).(type) {
+	case *parser.VectorSelector:
+		return selectedMetric(e)
+
+	case *parser.MatrixSelector:
+		vs, ok := e.VectorSelector.(*parser.VectorSelector)
+		if !ok {
+			return "", nil, errors.New("the expression doesn't select a metric")
+		}
+		return selectedMetric(vs)
+
+	case *parser.SubqueryExpr:
+		return nameOperations(e.Expr)
+
+	case *parser.AggregateExpr:
+		metric, ops, err := nameOperations(e.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return metric, appendOperation(e.Op.String(), ops), nil
+
+	case *parser.Call:
+		var arg parser.Expr
+		for _, a := range e.Args {
+			if t := a.Type(); t != parser.ValueTypeVector && t != parser.ValueTypeMatrix {
+				continue
+			}
+			if arg != nil {
+				return "", nil, fmt.Errorf("%s() of several metrics", e.Func.Name)
+			}
+			arg = a
+		}
+		if arg == nil {
+			return "", nil, fmt.Errorf("%s() doesn't select a metric", e.Func.Name)
+		}
+
+		metric, ops, err := nameOperations(arg)
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch e.Func.Name {
+		case "label_replace", "label_join":
+			// Labels are part of the level, not of the operations.
+			return metric, ops, nil
+		case "rate", "irate", "increase":
+			metric = strings.TrimSuffix(metric, "_total")
+		}
+
+		op := e.Func.Name
+		switch a := unwrapExpr(arg).(type) {
+		case *parser.MatrixSelector:
+			op += model.Duration(a.Range).String()
+		case *parser.SubqueryExpr:
+			op += model.Duration(a.Range).String()
+		}
+		return metric, appendOperation(op, ops), nil
+
+	case *parser.BinaryExpr:
+		switch {
+		case e.LHS.Type() != parser.ValueTypeVector:
+			return nameOperations(e.RHS)
+		case e.RHS.Type() != parser.ValueTypeVector:
+			return nameOperations(e.LHS)
+		}
+		return "", nil, errors.New("the expression combines several metrics")
+	}
+
+	return "", nil, errors.New("the expression doesn't select a metric")
+}
+
+// selectedMetric returns the metric of a selector. The metric of a recording
+// rule following the level:metric:operations format is its metric part.
+func selectedMetric(vs *parser.VectorSelector) (string, []string, error) {
+	name := vs.Name
+	if name == "" {
+		for _, m := range vs.LabelMatchers {
+			if m.Name == model.MetricNameLabel && m.Type == labels.MatchEqual {
+				name = m.Value
+			}
+		}
+	}
+	if name == "" {
+		return "", nil, errors.New("the expression doesn't select a metric by name")
+	}
+
+	if chunks := strings.Split(name, ":"); len(chunks) > 1 && chunks[1] != "" {
+		name = chunks[1]
+	}
+	return name, nil, nil
+}
+
+// appendOperation prepends op to the operations, the same operation applied
+// several times in a row being named once.
+func appendOperation(op string, ops []string) []string {
+	if len(ops) > 0 && ops[0] == op {
+		return ops
+	}
+	return append([]string{op}, ops...)
+}
+
+// omitSum removes the sum aggregations from the operations, unless it is the
+// only operation.
+func omitSum(ops []string) []string {
+	result := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op != "sum" {
+			result = append(result, op)
+		}
+	}
+	if len(result) == 0 {
+		return ops
+	}
+	return result
+}
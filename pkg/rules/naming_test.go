@@ -0,0 +1,104 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestConventionalRecordingName(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+		err      string
+	}{
+		{expr: `sum by (job) (up)`, expected: `job:up:sum`},
+		{expr: `sum by (job, instance) (rate(http_requests_total[5m]))`, expected: `instance_job:http_requests:rate5m`},
+		{expr: `max by (job) (sum by (job, instance) (irate(http_requests_total{code=~"5.."}[1m])))`, expected: `job:http_requests:max_irate1m`},
+		{expr: `avg by (job) (avg_over_time(up[1h:5m])) * 100`, expected: `job:up:avg_avg_over_time1h`},
+		{expr: `sum by (job) (instance:node_cpu:rate5m)`, expected: `job:node_cpu:sum`},
+		{expr: `sum by (job) ({__name__="up"})`, expected: `job:up:sum`},
+		{expr: `histogram_quantile(0.99, sum by (job, le) (rate(http_request_duration_seconds_bucket[5m])))`, err: "the expression doesn't aggregate by a known set of labels"},
+		{
+			expr:     `sum by (path) (rate(request_failures_total[5m])) / sum by (path) (rate(requests_total[5m]))`,
+			expected: `path:request_failures_per_requests:ratio_rate5m`,
+		},
+		{expr: `sum(up)`, err: "the expression doesn't aggregate by a known set of labels"},
+		{expr: `sum by (job) (up) + sum by (job) (node_up)`, err: "the expression combines several metrics"},
+		{expr: `count by (job) (vector(1))`, err: "vector() doesn't select a metric"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			name, err := ConventionalRecordingName(tc.expr)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, name)
+		})
+	}
+}
+
+func TestFixRecordingRules(t *testing.T) {
+	parse := func(content string) RuleNamespace {
+		var ns RuleNamespace
+		require.NoError(t, yaml.Unmarshal([]byte(content), &ns))
+		return ns
+	}
+	nss := map[string]RuleNamespace{
+		"a": parse(`
+namespace: a
+groups:
+  - name: requests
+    rules:
+      - record: requests_rate
+        expr: sum(rate(http_requests_total[5m])) by (job)
+      - record: requests_total_sum
+        expr: sum(http_requests_total)
+      - alert: HighRequestRate
+        expr: requests_rate > 100
+      - alert: Unrelated
+        expr: sum(up) by (job) > 0
+`),
+		"b": parse(`
+namespace: b
+groups:
+  - name: upness
+    rules:
+      - record: upness
+        expr: sum(up) by (job)
+      - record: job:up:sum
+        expr: sum by (job) (up{env="prod"})
+      - record: job:requests:max
+        expr: max by (job) (requests_rate)
+`),
+	}
+
+	fix, err := FixRecordingRules(nss, false)
+	require.NoError(t, err)
+	assert.Equal(t, []RecordingRuleRename{
+		{Namespace: "a", Group: "requests", From: "requests_rate", To: "job:http_requests:rate5m"},
+	}, fix.Renames)
+	// Rules without a derivable name or whose name is already recorded are
+	// left unfixed.
+	assert.Equal(t, 2, fix.Unfixed)
+	assert.Len(t, fix.Changes, 3)
+
+	rules := nss["a"].Groups[0].Rules
+	assert.Equal(t, "job:http_requests:rate5m", rules[0].Record.Value)
+	assert.Equal(t, "sum(rate(http_requests_total[5m])) by (job)", rules[0].Expr.Value)
+	assert.Equal(t, "requests_total_sum", rules[1].Record.Value)
+	assert.Equal(t, "job:http_requests:rate5m > 100", rules[2].Expr.Value)
+	assert.Equal(t, "upness", nss["b"].Groups[0].Rules[0].Record.Value)
+	// Expressions not referencing a renamed rule are left as written.
+	assert.Equal(t, "sum(up) by (job) > 0", rules[3].Expr.Value)
+	assert.Equal(t, "sum(up) by (job)", nss["b"].Groups[0].Rules[0].Expr.Value)
+	assert.Equal(t, "max by (job) (job:http_requests:rate5m)", nss["b"].Groups[0].Rules[2].Expr.Value)
+
+	// Fixed rules pass the check.
+	assert.Equal(t, 1, nss["a"].CheckRecordingRules(false))
+}
@@ -0,0 +1,197 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// Overlay patches rule groups and rules of the parsed rule files, matched by
+// name.
+type Overlay struct {
+	// Namespace restricts the overlay to the groups of a namespace. Groups are
+	// matched in every namespace if empty.
+	Namespace string         `yaml:"namespace,omitempty"`
+	Groups    []GroupOverlay `yaml:"groups"`
+
+	// File is the file the overlay was loaded from.
+	File string `yaml:"-"`
+}
+
+// GroupOverlay patches the rule groups of a given name. Unset fields are kept.
+type GroupOverlay struct {
+	Name          string          `yaml:"name"`
+	Interval      *model.Duration `yaml:"interval,omitempty"`
+	Limit         *int            `yaml:"limit,omitempty"`
+	SourceTenants []string        `yaml:"source_tenants,omitempty"`
+	Rules         []RuleOverlay   `yaml:"rules,omitempty"`
+}
+
+// RuleOverlay patches the rules of a group with a given alert or record name.
+// Unset fields are kept, and labels and annotations are merged into the ones
+// of the rule, an empty value removing them.
+type RuleOverlay struct {
+	Alert  string `yaml:"alert,omitempty"`
+	Record string `yaml:"record,omitempty"`
+	// MatchLabels further restricts the patched rules to those having these
+	// labels, to tell apart rules sharing a name.
+	MatchLabels   map[string]string `yaml:"match_labels,omitempty"`
+	Expr          string            `yaml:"expr,omitempty"`
+	For           *model.Duration   `yaml:"for,omitempty"`
+	KeepFiringFor *model.Duration   `yaml:"keep_firing_for,omitempty"`
+	Labels        map[string]string `yaml:"labels,omitempty"`
+	Annotations   map[string]string `yaml:"annotations,omitempty"`
+}
+
+// LoadOverlays reads overlay files, rejecting unknown fields.
+func LoadOverlays(files []string) ([]Overlay, error) {
+	overlays := make([]Overlay, 0, len(files))
+	for _, f := range files {
+		content, err := loadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+
+		var overlay Overlay
+		if err := decoder.Decode(&overlay); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse overlay file %s", f)
+		}
+		overlay.File = f
+
+		for _, g := range overlay.Groups {
+			if g.Name == "" {
+				return nil, fmt.Errorf("overlay file %s: group without a name", f)
+			}
+			for _, r := range g.Rules {
+				if (r.Alert == "") == (r.Record == "") {
+					return nil, fmt.Errorf("overlay file %s: rules of group %s must set exactly one of alert or record", f, g.Name)
+				}
+			}
+		}
+
+		overlays = append(overlays, overlay)
+	}
+	return overlays, nil
+}
+
+// ApplyOverlays patches the rule groups of the namespaces with the overlays, in
+// order. Every group and rule of an overlay must match at least one group or
+// rule, so overlays don't silently stop applying once the rules are renamed.
+// The patched namespaces are not validated.
+func ApplyOverlays(nss map[string]RuleNamespace, overlays []Overlay) error {
+	for _, overlay := range overlays {
+		for _, patch := range overlay.Groups {
+			var groupMatched bool
+			rulesMatched := make([]bool, len(patch.Rules))
+
+			for name, ns := range nss {
+				if overlay.Namespace != "" && overlay.Namespace != name {
+					continue
+				}
+
+				for i := range ns.Groups {
+					if ns.Groups[i].Name != patch.Name {
+						continue
+					}
+					groupMatched = true
+					applyGroupOverlay(&ns.Groups[i], patch, rulesMatched)
+				}
+			}
+
+			if !groupMatched {
+				return fmt.Errorf("overlay file %s: group %s not found", overlay.File, patch.Name)
+			}
+			for i, matched := range rulesMatched {
+				if matched {
+					continue
+				}
+				if rule := patch.Rules[i]; rule.Alert != "" {
+					return fmt.Errorf("overlay file %s: alert %s not found in group %s", overlay.File, rule.Alert, patch.Name)
+				}
+				return fmt.Errorf("overlay file %s: recording rule %s not found in group %s", overlay.File, patch.Rules[i].Record, patch.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyGroupOverlay patches a group, recording which rule patches matched.
+func applyGroupOverlay(g *rwrulefmt.RuleGroup, patch GroupOverlay, rulesMatched []bool) {
+	if patch.Interval != nil {
+		g.Interval = *patch.Interval
+	}
+	if patch.Limit != nil {
+		g.Limit = *patch.Limit
+	}
+	if patch.SourceTenants != nil {
+		g.SourceTenants = patch.SourceTenants
+	}
+
+	for i, rp := range patch.Rules {
+		for j := range g.Rules {
+			if rp.matches(g.Rules[j]) {
+				rulesMatched[i] = true
+				rp.apply(&g.Rules[j])
+			}
+		}
+	}
+}
+
+func (p RuleOverlay) matches(r rulefmt.RuleNode) bool {
+	if p.Alert != r.Alert.Value || p.Record != r.Record.Value {
+		return false
+	}
+	for name, value := range p.MatchLabels {
+		if r.Labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (p RuleOverlay) apply(r *rulefmt.RuleNode) {
+	if p.Expr != "" {
+		r.Expr.SetString(p.Expr)
+	}
+	if p.For != nil {
+		r.For = *p.For
+	}
+	if p.KeepFiringFor != nil {
+		r.KeepFiringFor = *p.KeepFiringFor
+	}
+	r.Labels = mergeOverlayMap(r.Labels, p.Labels)
+	r.Annotations = mergeOverlayMap(r.Annotations, p.Annotations)
+}
+
+// mergeOverlayMap returns a copy of m with the values of patch, removing the
+// keys with an empty value.
+func mergeOverlayMap(m, patch map[string]string) map[string]string {
+	if len(patch) == 0 {
+		return m
+	}
+
+	merged := make(map[string]string, len(m)+len(patch))
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range patch {
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
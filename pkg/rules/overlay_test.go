@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestApplyOverlays(t *testing.T) {
+	rule := func(alert, expr string, labels map[string]string) rulefmt.RuleNode {
+		return rulefmt.RuleNode{
+			Alert:  yaml.Node{Kind: yaml.ScalarNode, Value: alert},
+			Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: expr},
+			Labels: labels,
+		}
+	}
+	namespaces := func() map[string]RuleNamespace {
+		return map[string]RuleNamespace{
+			"a": {Namespace: "a", Groups: []rwrulefmt.RuleGroup{{RuleGroup: rulefmt.RuleGroup{Name: "shared", Rules: []rulefmt.RuleNode{
+				rule("Down", "up == 0", map[string]string{"severity": "page", "team": "a"}),
+			}}}}},
+			"b": {Namespace: "b", Groups: []rwrulefmt.RuleGroup{{RuleGroup: rulefmt.RuleGroup{Name: "shared", Rules: []rulefmt.RuleNode{
+				rule("Down", "up == 0", nil),
+			}}}}},
+		}
+	}
+	limit := 10
+
+	tests := []struct {
+		name     string
+		overlays []Overlay
+		check    func(t *testing.T, nss map[string]RuleNamespace)
+		wantErr  string
+	}{
+		{
+			name: "groups of every namespace",
+			overlays: []Overlay{{Groups: []GroupOverlay{{
+				Name:          "shared",
+				Limit:         &limit,
+				SourceTenants: []string{"t1"},
+				Rules:         []RuleOverlay{{Alert: "Down", Expr: "up{job=\"api\"} == 0", Labels: map[string]string{"severity": "ticket", "team": ""}}},
+			}}}},
+			check: func(t *testing.T, nss map[string]RuleNamespace) {
+				for _, ns := range nss {
+					require.Equal(t, 10, ns.Groups[0].Limit)
+					require.Equal(t, []string{"t1"}, ns.Groups[0].SourceTenants)
+					require.Equal(t, "up{job=\"api\"} == 0", ns.Groups[0].Rules[0].Expr.Value)
+					require.Equal(t, map[string]string{"severity": "ticket"}, ns.Groups[0].Rules[0].Labels)
+				}
+			},
+		},
+		{
+			name:     "groups of a namespace",
+			overlays: []Overlay{{Namespace: "b", Groups: []GroupOverlay{{Name: "shared", Limit: &limit}}}},
+			check: func(t *testing.T, nss map[string]RuleNamespace) {
+				require.Equal(t, 0, nss["a"].Groups[0].Limit)
+				require.Equal(t, 10, nss["b"].Groups[0].Limit)
+			},
+		},
+		{
+			name:     "rules matching labels",
+			overlays: []Overlay{{Groups: []GroupOverlay{{Name: "shared", Rules: []RuleOverlay{{Alert: "Down", MatchLabels: map[string]string{"team": "a"}, Annotations: map[string]string{"summary": "down"}}}}}}},
+			check: func(t *testing.T, nss map[string]RuleNamespace) {
+				require.Equal(t, map[string]string{"summary": "down"}, nss["a"].Groups[0].Rules[0].Annotations)
+				require.Nil(t, nss["b"].Groups[0].Rules[0].Annotations)
+			},
+		},
+		{
+			name:     "unknown group",
+			overlays: []Overlay{{File: "overlay.yaml", Groups: []GroupOverlay{{Name: "missing"}}}},
+			wantErr:  "overlay file overlay.yaml: group missing not found",
+		},
+		{
+			name:     "group of another namespace",
+			overlays: []Overlay{{File: "overlay.yaml", Namespace: "c", Groups: []GroupOverlay{{Name: "shared"}}}},
+			wantErr:  "overlay file overlay.yaml: group shared not found",
+		},
+		{
+			name:     "unknown rule",
+			overlays: []Overlay{{File: "overlay.yaml", Namespace: "a", Groups: []GroupOverlay{{Name: "shared", Rules: []RuleOverlay{{Record: "Down"}}}}}},
+			wantErr:  "overlay file overlay.yaml: recording rule Down not found in group shared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nss := namespaces()
+			err := ApplyOverlays(nss, tt.overlays)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, nss)
+		})
+	}
+}
+
+func TestLoadOverlays(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	overlays, err := LoadOverlays([]string{write("valid.yaml", "groups:\n  - name: g\n    interval: 1m\n")})
+	require.NoError(t, err)
+	require.Len(t, overlays, 1)
+	require.Equal(t, "g", overlays[0].Groups[0].Name)
+	require.NotNil(t, overlays[0].Groups[0].Interval)
+
+	_, err = LoadOverlays([]string{write("unknown.yaml", "groups:\n  - name: g\n    unknown: 1\n")})
+	require.Error(t, err)
+
+	_, err = LoadOverlays([]string{write("unnamed.yaml", "groups:\n  - interval: 1m\n")})
+	require.Error(t, err)
+
+	_, err = LoadOverlays([]string{write("rule.yaml", "groups:\n  - name: g\n    rules:\n      - expr: up\n")})
+	require.Error(t, err)
+}
@@ -22,23 +22,54 @@ const (
 var (
 	errFileReadError  = errors.New("file read error")
 	errInvalidBackend = errors.New("invalid backend type")
+	errInvalidFormat  = errors.New("invalid rule file format")
 )
 
 // ParseFiles returns a formatted set of prometheus rule groups
 func ParseFiles(backend string, files []string) (map[string]RuleNamespace, error) {
+	return ParseFilesWithOptions(backend, files, ParseOptions{})
+}
+
+// ParseFilesWithOptions returns a formatted set of prometheus rule groups from
+// rule files of the given format.
+func ParseFilesWithOptions(backend string, files []string, opts ParseOptions) (map[string]RuleNamespace, error) {
 	ruleSet := map[string]RuleNamespace{}
-	var parseFn func(f string) ([]RuleNamespace, []error)
+	var parseFn func(content []byte) ([]RuleNamespace, []error)
 	switch backend {
 	case CortexBackend:
-		parseFn = Parse
+		parseFn = ParseBytes
 	case LokiBackend:
-		parseFn = ParseLoki
+		parseFn = parseLokiBytes
 	default:
 		return nil, errInvalidBackend
 	}
 
+	switch opts.Format {
+	case "", CortextoolFormat:
+	case PrometheusRuleFormat:
+		parseFn = func(content []byte) ([]RuleNamespace, []error) {
+			return parsePrometheusRuleBytes(backend, content, opts.NamespaceTemplate)
+		}
+	default:
+		return nil, errInvalidFormat
+	}
+
 	for _, f := range files {
-		nss, errs := parseFn(f)
+		content, err := loadFile(f)
+		if err != nil {
+			log.WithError(err).WithField("file", f).Errorln("unable load rules file")
+			return nil, errFileReadError
+		}
+
+		if opts.Values != nil {
+			content, err = RenderTemplate(f, content, opts.Values)
+			if err != nil {
+				log.WithError(err).WithField("file", f).Errorln("unable to render rules file")
+				return nil, errFileReadError
+			}
+		}
+
+		nss, errs := parseFn(content)
 		for _, err := range errs {
 			log.WithError(err).WithField("file", f).Errorln("unable parse rules file")
 			return nil, errFileReadError
@@ -46,6 +77,7 @@ func ParseFiles(backend string, files []string) (map[string]RuleNamespace, error
 
 		for _, ns := range nss {
 			ns.Filepath = f
+			ns.rendered = opts.Values != nil || len(opts.Overlays) > 0
 
 			// Determine if the namespace is explicitly set. If not
 			// the file name without the extension is used.
@@ -66,6 +98,23 @@ func ParseFiles(backend string, files []string) (map[string]RuleNamespace, error
 			ruleSet[namespace] = ns
 		}
 	}
+
+	if len(opts.Overlays) > 0 {
+		if err := ApplyOverlays(ruleSet, opts.Overlays); err != nil {
+			return nil, err
+		}
+
+		// Overlays may patch the rules into invalid ones.
+		for _, ns := range ruleSet {
+			for _, err := range validateNamespace(backend, ns) {
+				log.WithError(err).WithFields(log.Fields{
+					"namespace": ns.Namespace,
+					"file":      ns.Filepath,
+				}).Errorln("invalid rules after applying overlays")
+				return nil, errFileReadError
+			}
+		}
+	}
 	return ruleSet, nil
 }
 
@@ -85,8 +134,8 @@ func ParseBytes(content []byte) ([]RuleNamespace, []error) {
 	decoder.KnownFields(true)
 
 	var nss []RuleNamespace
-	for {
-		var ns RuleNamespace
+	for doc := 0; ; doc++ {
+		ns := RuleNamespace{document: doc}
 		err := decoder.Decode(&ns)
 		if err == io.EOF {
 			break
@@ -111,12 +160,16 @@ func ParseLoki(f string) ([]RuleNamespace, []error) {
 		return nil, []error{errFileReadError}
 	}
 
+	return parseLokiBytes(content)
+}
+
+func parseLokiBytes(content []byte) ([]RuleNamespace, []error) {
 	decoder := yaml.NewDecoder(bytes.NewReader(content))
 	decoder.KnownFields(true)
 
 	var nss []RuleNamespace
-	for {
-		var ns RuleNamespace
+	for doc := 0; ; doc++ {
+		ns := RuleNamespace{document: doc}
 		err := decoder.Decode(&ns)
 		if err == io.EOF {
 			break
@@ -125,14 +178,7 @@ func ParseLoki(f string) ([]RuleNamespace, []error) {
 			return nil, []error{err}
 		}
 
-		// the upstream loki validator only validates the rulefmt rule groups,
-		// not the remote write configs this type attaches.
-		var grps []rulefmt.RuleGroup
-		for _, g := range ns.Groups {
-			grps = append(grps, g.RuleGroup)
-		}
-
-		if errs := ruler.ValidateGroups(grps...); len(errs) > 0 {
+		if errs := validateNamespace(LokiBackend, ns); len(errs) > 0 {
 			return nil, errs
 		}
 
@@ -142,6 +188,37 @@ func ParseLoki(f string) ([]RuleNamespace, []error) {
 	return nss, nil
 }
 
+// parsePrometheusRuleBytes parses and validates the PrometheusRule resources of
+// a file.
+func parsePrometheusRuleBytes(backend string, content []byte, namespaceTemplate string) ([]RuleNamespace, []error) {
+	nss, err := ParsePrometheusRules(content, namespaceTemplate)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	for _, ns := range nss {
+		if errs := validateNamespace(backend, ns); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+	return nss, nil
+}
+
+// validateNamespace validates the rule groups of a namespace for a backend.
+func validateNamespace(backend string, ns RuleNamespace) []error {
+	if backend != LokiBackend {
+		return ns.Validate()
+	}
+
+	// the upstream loki validator only validates the rulefmt rule groups,
+	// not the remote write configs this type attaches.
+	var grps []rulefmt.RuleGroup
+	for _, g := range ns.Groups {
+		grps = append(grps, g.RuleGroup)
+	}
+	return ruler.ValidateGroups(grps...)
+}
+
 func loadFile(filename string) ([]byte, error) {
 	file, err := os.Open(filename)
 	if err != nil {
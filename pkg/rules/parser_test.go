@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
 
 	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
 )
@@ -238,3 +240,24 @@ func compareNamespace(g, w RuleNamespace) error {
 
 	return nil
 }
+
+func TestParseFiles_FederatedRuleGroups(t *testing.T) {
+	nss, err := ParseFiles(CortexBackend, []string{"testdata/federated_namespace.yaml"})
+	require.NoError(t, err)
+
+	group := nss["federated"].Groups[0]
+	require.Equal(t, 100, group.Limit)
+	require.Equal(t, []string{"tenant-a", "tenant-b"}, group.SourceTenants)
+
+	payload, err := yaml.Marshal(group)
+	require.NoError(t, err)
+	require.Equal(t, `name: cross_tenant
+limit: 100
+rules:
+    - record: job:up:sum
+      expr: sum by (job) (up)
+source_tenants:
+    - tenant-a
+    - tenant-b
+`, string(payload))
+}
@@ -0,0 +1,192 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Policy is an organization policy enforced on rule files by `rules check`.
+// Every check is disabled unless it is configured.
+type Policy struct {
+	Groups         GroupPolicy         `yaml:"groups"`
+	Alerts         AlertPolicy         `yaml:"alerts"`
+	RecordingRules RecordingRulePolicy `yaml:"recording_rules"`
+}
+
+// GroupPolicy holds the checks run against every rule group.
+type GroupPolicy struct {
+	// NamePattern is a regular expression group names must fully match.
+	NamePattern string `yaml:"name_pattern"`
+
+	namePattern *regexp.Regexp
+}
+
+// AlertPolicy holds the checks run against every alerting rule.
+type AlertPolicy struct {
+	// NamePattern is a regular expression alert names must fully match.
+	NamePattern string `yaml:"name_pattern"`
+	// RequiredLabels maps the labels every alert must set to their allowed
+	// values. An empty list allows any value.
+	RequiredLabels map[string][]string `yaml:"required_labels"`
+	// RequiredAnnotations are the annotations every alert must set.
+	RequiredAnnotations []string `yaml:"required_annotations"`
+	// MinFor is the minimum `for` duration of every alert.
+	MinFor model.Duration `yaml:"min_for"`
+
+	namePattern *regexp.Regexp
+}
+
+// RecordingRulePolicy holds the checks run against every recording rule.
+type RecordingRulePolicy struct {
+	// NamePattern is a regular expression recording rule names must fully match.
+	NamePattern string `yaml:"name_pattern"`
+
+	namePattern *regexp.Regexp
+}
+
+// Names of the policy checks, as reported in findings.
+const (
+	GroupNameCheck          = "group-name"
+	AlertNameCheck          = "alert-name"
+	RequiredLabelCheck      = "required-label"
+	AllowedLabelValueCheck  = "allowed-label-value"
+	RequiredAnnotationCheck = "required-annotation"
+	MinForCheck             = "min-for"
+	RecordingRuleNameCheck  = "recording-rule-name"
+)
+
+// Finding is a rule that doesn't comply with a check.
+type Finding struct {
+	File      string
+	Namespace string
+	Group     string
+	// Rule is empty for findings about the group itself.
+	Rule    string
+	Check   string
+	Message string
+}
+
+// LoadPolicy reads and validates a policy file.
+func LoadPolicy(filename string) (*Policy, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read policy file")
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	p := &Policy{}
+	if err := decoder.Decode(p); err != nil {
+		return nil, errors.Wrap(err, "unable to parse policy file")
+	}
+
+	if p.Groups.namePattern, err = compileNamePattern(p.Groups.NamePattern); err != nil {
+		return nil, errors.Wrap(err, "invalid groups name_pattern")
+	}
+	if p.Alerts.namePattern, err = compileNamePattern(p.Alerts.NamePattern); err != nil {
+		return nil, errors.Wrap(err, "invalid alerts name_pattern")
+	}
+	if p.RecordingRules.namePattern, err = compileNamePattern(p.RecordingRules.NamePattern); err != nil {
+		return nil, errors.Wrap(err, "invalid recording_rules name_pattern")
+	}
+
+	return p, nil
+}
+
+// compileNamePattern compiles a fully anchored regular expression, it returns
+// nil if the pattern is empty.
+func compileNamePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// Check returns the findings of every rule group of the namespace, in the
+// order of the groups and rules.
+func (p *Policy) Check(ns RuleNamespace) []Finding {
+	var findings []Finding
+
+	for _, g := range ns.Groups {
+		newFinding := func(rule, check, format string, args ...interface{}) Finding {
+			return Finding{
+				File:      ns.Filepath,
+				Namespace: ns.Namespace,
+				Group:     g.Name,
+				Rule:      rule,
+				Check:     check,
+				Message:   fmt.Sprintf(format, args...),
+			}
+		}
+
+		if re := p.Groups.namePattern; re != nil && !re.MatchString(g.Name) {
+			findings = append(findings, newFinding("", GroupNameCheck, "group name does not match %q", p.Groups.NamePattern))
+		}
+
+		for _, r := range g.Rules {
+			if r.Record.Value != "" {
+				if re := p.RecordingRules.namePattern; re != nil && !re.MatchString(r.Record.Value) {
+					findings = append(findings, newFinding(r.Record.Value, RecordingRuleNameCheck, "recording rule name does not match %q", p.RecordingRules.NamePattern))
+				}
+				continue
+			}
+
+			name := r.Alert.Value
+			if re := p.Alerts.namePattern; re != nil && !re.MatchString(name) {
+				findings = append(findings, newFinding(name, AlertNameCheck, "alert name does not match %q", p.Alerts.NamePattern))
+			}
+
+			for _, label := range sortedKeys(p.Alerts.RequiredLabels) {
+				value, ok := r.Labels[label]
+				if !ok {
+					findings = append(findings, newFinding(name, RequiredLabelCheck, "missing required label %q", label))
+					continue
+				}
+
+				allowed := p.Alerts.RequiredLabels[label]
+				if len(allowed) > 0 && !contains(allowed, value) {
+					findings = append(findings, newFinding(name, AllowedLabelValueCheck, "label %q has value %q, allowed values are: %s", label, value, strings.Join(allowed, ", ")))
+				}
+			}
+
+			for _, annotation := range p.Alerts.RequiredAnnotations {
+				if _, ok := r.Annotations[annotation]; !ok {
+					findings = append(findings, newFinding(name, RequiredAnnotationCheck, "missing required annotation %q", annotation))
+				}
+			}
+
+			if p.Alerts.MinFor > 0 && r.For < p.Alerts.MinFor {
+				findings = append(findings, newFinding(name, MinForCheck, "for duration %s is shorter than the minimum of %s", r.For, p.Alerts.MinFor))
+			}
+		}
+	}
+
+	return findings
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
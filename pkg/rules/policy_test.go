@@ -0,0 +1,46 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := LoadPolicy("testdata/policy.yaml")
+	require.NoError(t, err)
+
+	nss, err := ParseFiles(CortexBackend, []string{"testdata/policy_namespace.yaml"})
+	require.NoError(t, err)
+
+	finding := func(group, rule, check, message string) Finding {
+		return Finding{
+			File:      "testdata/policy_namespace.yaml",
+			Namespace: "policy",
+			Group:     group,
+			Rule:      rule,
+			Check:     check,
+			Message:   message,
+		}
+	}
+
+	require.Equal(t, []Finding{
+		finding("Bad-Group", "", GroupNameCheck, `group name does not match "[a-z0-9_]+"`),
+		finding("Bad-Group", "summed_up", RecordingRuleNameCheck, `recording rule name does not match "[a-z_]+:[a-z_]+:[a-z0-9_]+"`),
+		finding("alerts", "high_latency", AlertNameCheck, `alert name does not match "[A-Z][A-Za-z0-9]+"`),
+		finding("alerts", "high_latency", AllowedLabelValueCheck, `label "severity" has value "page", allowed values are: critical, warning`),
+		finding("alerts", "high_latency", RequiredLabelCheck, `missing required label "team"`),
+		finding("alerts", "high_latency", RequiredAnnotationCheck, `missing required annotation "runbook_url"`),
+		finding("alerts", "high_latency", MinForCheck, "for duration 1m is shorter than the minimum of 5m"),
+	}, policy.Check(nss["policy"]))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	_, err := LoadPolicy("testdata/missing.yaml")
+	require.Error(t, err)
+
+	// An empty policy enables no check.
+	nss, err := ParseFiles(CortexBackend, []string{"testdata/policy_namespace.yaml"})
+	require.NoError(t, err)
+	require.Empty(t, (&Policy{}).Check(nss["policy"]))
+}
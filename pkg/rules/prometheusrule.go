@@ -0,0 +1,299 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+// Formats of the rule files.
+const (
+	CortextoolFormat     = "cortextool"
+	PrometheusRuleFormat = "prometheusrule"
+)
+
+const (
+	// DefaultNamespaceTemplate maps a PrometheusRule resource to a Cortex
+	// namespace. Kubernetes names can't contain underscores, so distinct
+	// resources can't be mapped to the same namespace.
+	DefaultNamespaceTemplate = "{{ .Namespace }}_{{ .Name }}"
+
+	prometheusRuleKind       = "PrometheusRule"
+	prometheusRuleAPIVersion = "monitoring.coreos.com/v1"
+	defaultKubernetesNS      = "default"
+)
+
+// ParseOptions configures how rule files are parsed.
+type ParseOptions struct {
+	// Format is the format of the rule files, cortextool if empty.
+	Format string
+	// NamespaceTemplate is the text/template mapping the metadata of a
+	// PrometheusRule resource to a Cortex namespace, DefaultNamespaceTemplate
+	// if empty.
+	NamespaceTemplate string
+	// Values are the values rule files are rendered with as templates before
+	// being parsed, see RenderTemplate. Files are not rendered if nil.
+	Values map[string]interface{}
+	// Overlays are applied in order to the parsed rule groups.
+	Overlays []Overlay
+}
+
+// PrometheusRule is a PrometheusRule resource of the Prometheus operator.
+type PrometheusRule struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   yaml.Node          `yaml:"metadata"`
+	Spec       PrometheusRuleSpec `yaml:"spec"`
+}
+
+// PrometheusRuleSpec holds the rule groups of a PrometheusRule resource.
+type PrometheusRuleSpec struct {
+	Groups []rwrulefmt.RuleGroup `yaml:"groups"`
+}
+
+// PrometheusRuleSource is the PrometheusRule resource a namespace was parsed
+// from.
+type PrometheusRuleSource struct {
+	APIVersion string
+	// Metadata is kept as is so the resource can be written back unchanged.
+	Metadata yaml.Node
+
+	// document is the index of the YAML document of the resource in its file,
+	// and item its index in the items of a list, or -1.
+	document int
+	item     int
+}
+
+// objectMeta holds the metadata fields used to name a namespace.
+type objectMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// ParsePrometheusRules parses the PrometheusRule resources of a set of YAML
+// documents, which may also be lists of resources. Documents of other kinds
+// are skipped. Every resource is mapped to a namespace named by executing the
+// namespace template with its metadata, the Kubernetes namespace defaulting to
+// "default". The rule groups are not validated.
+func ParsePrometheusRules(content []byte, namespaceTemplate string) ([]RuleNamespace, error) {
+	if namespaceTemplate == "" {
+		namespaceTemplate = DefaultNamespaceTemplate
+	}
+	tmpl, err := template.New("namespace").Option("missingkey=error").Parse(namespaceTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid namespace template")
+	}
+
+	var nss []RuleNamespace
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for doc := 0; ; doc++ {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var header struct {
+			Kind  string      `yaml:"kind"`
+			Items []yaml.Node `yaml:"items"`
+		}
+		if err := node.Decode(&header); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case header.Kind == prometheusRuleKind:
+			ns, err := parsePrometheusRule(&node, tmpl)
+			if err != nil {
+				return nil, err
+			}
+			ns.Source.document, ns.Source.item = doc, -1
+			nss = append(nss, ns)
+		case strings.HasSuffix(header.Kind, "List"):
+			for i := range header.Items {
+				var item struct {
+					Kind string `yaml:"kind"`
+				}
+				if err := header.Items[i].Decode(&item); err != nil {
+					return nil, err
+				}
+				if item.Kind != prometheusRuleKind {
+					log.WithFields(log.Fields{"kind": item.Kind, "document": doc, "item": i}).Debugln("skipping resource")
+					continue
+				}
+
+				ns, err := parsePrometheusRule(&header.Items[i], tmpl)
+				if err != nil {
+					return nil, err
+				}
+				ns.Source.document, ns.Source.item = doc, i
+				nss = append(nss, ns)
+			}
+		default:
+			log.WithFields(log.Fields{"kind": header.Kind, "document": doc}).Debugln("skipping resource")
+		}
+	}
+
+	return nss, nil
+}
+
+// parsePrometheusRule decodes a PrometheusRule resource, rejecting unknown
+// fields outside of its metadata.
+func parsePrometheusRule(node *yaml.Node, tmpl *template.Template) (RuleNamespace, error) {
+	payload, err := yaml.Marshal(node)
+	if err != nil {
+		return RuleNamespace{}, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(payload))
+	decoder.KnownFields(true)
+
+	var rule PrometheusRule
+	if err := decoder.Decode(&rule); err != nil {
+		return RuleNamespace{}, err
+	}
+
+	var meta objectMeta
+	if err := rule.Metadata.Decode(&meta); err != nil {
+		return RuleNamespace{}, err
+	}
+	if meta.Name == "" {
+		return RuleNamespace{}, errors.New("PrometheusRule resource without a name")
+	}
+	if meta.Namespace == "" {
+		meta.Namespace = defaultKubernetesNS
+	}
+
+	var name strings.Builder
+	if err := tmpl.Execute(&name, meta); err != nil {
+		return RuleNamespace{}, errors.Wrapf(err, "unable to name the namespace of PrometheusRule %s/%s", meta.Namespace, meta.Name)
+	}
+	if name.Len() == 0 {
+		return RuleNamespace{}, fmt.Errorf("empty namespace name for PrometheusRule %s/%s", meta.Namespace, meta.Name)
+	}
+
+	return RuleNamespace{
+		Namespace: name.String(),
+		Groups:    rule.Spec.Groups,
+		Source: &PrometheusRuleSource{
+			APIVersion: rule.APIVersion,
+			Metadata:   rule.Metadata,
+		},
+	}, nil
+}
+
+// ToPrometheusRule converts a namespace into a PrometheusRule resource. The
+// resource keeps the metadata of the resource the namespace was parsed from,
+// if any, or else is named after the namespace.
+func ToPrometheusRule(ns RuleNamespace) (PrometheusRule, error) {
+	rule := PrometheusRule{
+		APIVersion: prometheusRuleAPIVersion,
+		Kind:       prometheusRuleKind,
+		Spec:       PrometheusRuleSpec{Groups: ns.Groups},
+	}
+
+	if ns.Source != nil {
+		rule.APIVersion = ns.Source.APIVersion
+		rule.Metadata = ns.Source.Metadata
+		return rule, nil
+	}
+
+	if err := rule.Metadata.Encode(objectMeta{Name: ns.Namespace}); err != nil {
+		return PrometheusRule{}, err
+	}
+	return rule, nil
+}
+
+// MarshalPrometheusRules converts namespaces into PrometheusRule resources,
+// written as a set of YAML documents.
+func MarshalPrometheusRules(nss []RuleNamespace) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	for _, ns := range nss {
+		rule, err := ToPrometheusRule(ns)
+		if err != nil {
+			return nil, err
+		}
+		if err := encoder.Encode(rule); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UpdatePrometheusRules updates the rule groups of the PrometheusRule
+// resources of a set of YAML documents with the groups of the namespaces
+// parsed from them, like UpdateRuleFile. Other documents and fields are kept,
+// and the content is returned unchanged if no value changed.
+func UpdatePrometheusRules(content []byte, nss []RuleNamespace) ([]byte, error) {
+	for _, ns := range nss {
+		if ns.Source == nil {
+			return nil, fmt.Errorf("namespace %s was not parsed from a PrometheusRule resource", ns.Namespace)
+		}
+	}
+
+	docs, err := decodeDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var e fileEditor
+	for _, ns := range nss {
+		pos := ns.Source
+		if pos.document >= len(docs) || len(docs[pos.document].Content) == 0 {
+			return nil, fmt.Errorf("document %d not found", pos.document)
+		}
+		resource := docs[pos.document].Content[0]
+		if pos.item >= 0 {
+			items := mappingValue(resource, "items")
+			if items == nil || pos.item >= len(items.Content) {
+				return nil, fmt.Errorf("item %d of document %d not found", pos.item, pos.document)
+			}
+			resource = items.Content[pos.item]
+		}
+
+		spec := mappingValue(resource, "spec")
+		if spec == nil {
+			return nil, fmt.Errorf("spec of document %d not found", pos.document)
+		}
+		if err := e.setRuleGroups(spec, ns); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.write(content, docs)
+}
+
+// mappingValue returns the value of a key of a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	_, value := mappingEntry(node, key)
+	return value
+}
+
+// mappingEntry returns the key and value nodes of a key of a mapping node, or
+// nil.
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,145 @@
+package rules
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestParsePrometheusRules(t *testing.T) {
+	content, err := os.ReadFile("testdata/prometheus_rules.yaml")
+	require.NoError(t, err)
+
+	nss, err := ParsePrometheusRules(content, "")
+	require.NoError(t, err)
+	require.Len(t, nss, 2)
+
+	require.Equal(t, "monitoring_api", nss[0].Namespace)
+	require.Equal(t, "api", nss[0].Groups[0].Name)
+	require.Equal(t, "job:up:sum", nss[0].Groups[0].Rules[0].Record.Value)
+	require.Equal(t, 0, nss[0].Source.document)
+	require.Equal(t, -1, nss[0].Source.item)
+
+	require.Equal(t, "default_node", nss[1].Namespace)
+	require.Equal(t, "NodeDown", nss[1].Groups[0].Rules[0].Alert.Value)
+	require.Equal(t, 2, nss[1].Source.document)
+	require.Equal(t, 0, nss[1].Source.item)
+
+	nss, err = ParsePrometheusRules(content, "k8s-{{ .Name }}")
+	require.NoError(t, err)
+	require.Equal(t, "k8s-api", nss[0].Namespace)
+	require.Equal(t, "k8s-node", nss[1].Namespace)
+
+	_, err = ParsePrometheusRules(content, "{{ .Cluster }}")
+	require.Error(t, err)
+
+	_, err = ParsePrometheusRules([]byte(`
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: api
+spec:
+  groups:
+  - name: api
+    unknown: field
+`), "")
+	require.Error(t, err)
+}
+
+func TestParseFilesWithOptions_PrometheusRule(t *testing.T) {
+	nss, err := ParseFilesWithOptions(CortexBackend, []string{"testdata/prometheus_rules.yaml"}, ParseOptions{Format: PrometheusRuleFormat})
+	require.NoError(t, err)
+	require.Len(t, nss, 2)
+	require.Equal(t, "testdata/prometheus_rules.yaml", nss["monitoring_api"].Filepath)
+	require.Equal(t, "testdata/prometheus_rules.yaml", nss["default_node"].Filepath)
+
+	// Both resources are mapped to the same namespace.
+	_, err = ParseFilesWithOptions(CortexBackend, []string{"testdata/prometheus_rules.yaml"}, ParseOptions{Format: PrometheusRuleFormat, NamespaceTemplate: "rules"})
+	require.Error(t, err)
+
+	_, err = ParseFilesWithOptions(CortexBackend, []string{"testdata/prometheus_rules.yaml"}, ParseOptions{Format: "jsonnet"})
+	require.Equal(t, errInvalidFormat, err)
+}
+
+func TestUpdatePrometheusRules(t *testing.T) {
+	content, err := os.ReadFile("testdata/prometheus_rules.yaml")
+	require.NoError(t, err)
+
+	nss, err := ParsePrometheusRules(content, "")
+	require.NoError(t, err)
+	nss[0].Groups[0].Rules[0].Expr.Value = "sum by (job, instance) (up)"
+	nss[1].Groups[0].Rules = append(nss[1].Groups[0].Rules, rulefmt.RuleNode{
+		Alert: yaml.Node{Kind: yaml.ScalarNode, Value: "NodeMissing"},
+		Expr:  yaml.Node{Kind: yaml.ScalarNode, Value: `absent(up{job="node"})`},
+	})
+
+	updated, err := UpdatePrometheusRules(content, nss)
+	require.NoError(t, err)
+
+	reparsed, err := ParsePrometheusRules(updated, "")
+	require.NoError(t, err)
+	require.Len(t, reparsed, 2)
+	require.Equal(t, "sum by (job, instance) (up)", reparsed[0].Groups[0].Rules[0].Expr.Value)
+	require.Len(t, reparsed[1].Groups[0].Rules, 2)
+	require.Equal(t, "NodeMissing", reparsed[1].Groups[0].Rules[1].Alert.Value)
+
+	// Other resources and the metadata of the updated ones are kept.
+	require.Contains(t, string(updated), "kind: ConfigMap")
+	require.Contains(t, string(updated), "kind: Service")
+	require.Contains(t, string(updated), "team: api")
+
+	_, err = UpdatePrometheusRules(content, []RuleNamespace{{Namespace: "other"}})
+	require.Error(t, err)
+}
+
+func TestUpdatePrometheusRules_InPlace(t *testing.T) {
+	content, err := os.ReadFile("testdata/prometheus_rules.yaml")
+	require.NoError(t, err)
+
+	nss, err := ParsePrometheusRules(content, "")
+	require.NoError(t, err)
+	nss[0].Groups[0].Rules[0].Expr.Value = "sum by (job, instance) (up)"
+
+	// Only the line of the changed expression differs.
+	updated, err := UpdatePrometheusRules(content, nss)
+	require.NoError(t, err)
+	require.Equal(t, strings.Replace(string(content), "expr: sum by (job) (up)", "expr: sum by (job, instance) (up)", 1), string(updated))
+}
+
+func TestMarshalPrometheusRules(t *testing.T) {
+	groups := []rwrulefmt.RuleGroup{{
+		RuleGroup: rulefmt.RuleGroup{
+			Name: "group",
+			Rules: []rulefmt.RuleNode{{
+				Record: yaml.Node{Kind: yaml.ScalarNode, Value: "job:up:sum"},
+				Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: "sum by (job) (up)"},
+			}},
+		},
+	}}
+
+	payload, err := MarshalPrometheusRules([]RuleNamespace{{Namespace: "example", Groups: groups}})
+	require.NoError(t, err)
+	require.Equal(t, `apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+    name: example
+spec:
+    groups:
+        - name: group
+          rules:
+            - record: job:up:sum
+              expr: sum by (job) (up)
+`, string(payload))
+
+	nss, err := ParsePrometheusRules(payload, "{{ .Name }}")
+	require.NoError(t, err)
+	require.Len(t, nss, 1)
+	require.Equal(t, "example", nss[0].Namespace)
+	require.Equal(t, groups[0].Name, nss[0].Groups[0].Name)
+}
@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Delimiters of the actions of templated rule files. They differ from the
+// default ones, so the {{ }} templates of alert annotations are kept as is.
+const (
+	TemplateLeftDelim  = "[["
+	TemplateRightDelim = "]]"
+)
+
+// templateData is the data templated rule files are executed with.
+type templateData struct {
+	Values map[string]interface{}
+}
+
+// LoadValues reads the values of templated rule files from a YAML file.
+func LoadValues(file string) (map[string]interface{}, error) {
+	content, err := loadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse values file %s", file)
+	}
+	return values, nil
+}
+
+// RenderTemplate executes a rule file as a text/template using the [[ and ]]
+// delimiters, with its values available as .Values. Referencing a missing
+// value is an error.
+func RenderTemplate(name string, content []byte, values map[string]interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Delims(TemplateLeftDelim, TemplateRightDelim).
+		Option("missingkey=error").
+		Parse(string(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid rule file template")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData{Values: values}); err != nil {
+		return nil, errors.Wrap(err, "unable to render rule file template")
+	}
+	return buf.Bytes(), nil
+}
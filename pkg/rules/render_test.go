@@ -0,0 +1,55 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	values := map[string]interface{}{"cluster": "prod", "threshold": 5}
+
+	out, err := RenderTemplate("rules.yaml", []byte(`expr: up{cluster="[[ .Values.cluster ]]"} > [[ .Values.threshold ]]
+summary: "{{ $labels.instance }} is down"
+`), values)
+	require.NoError(t, err)
+	require.Equal(t, `expr: up{cluster="prod"} > 5
+summary: "{{ $labels.instance }} is down"
+`, string(out))
+
+	_, err = RenderTemplate("rules.yaml", []byte(`[[ .Values.missing ]]`), values)
+	require.Error(t, err)
+
+	_, err = RenderTemplate("rules.yaml", []byte(`[[ .Values.cluster `), values)
+	require.Error(t, err)
+}
+
+func TestParseFilesWithValuesAndOverlays(t *testing.T) {
+	values, err := LoadValues("testdata/templated/values.yaml")
+	require.NoError(t, err)
+	overlays, err := LoadOverlays([]string{"testdata/templated/overlay.yaml"})
+	require.NoError(t, err)
+
+	nss, err := ParseFilesWithOptions(CortexBackend, []string{"testdata/templated/rules.yaml"}, ParseOptions{Values: values, Overlays: overlays})
+	require.NoError(t, err)
+
+	group := nss["api"].Groups[0]
+	require.Equal(t, model.Duration(2*time.Minute), group.Interval)
+
+	warning, critical := group.Rules[0], group.Rules[1]
+	require.Equal(t, `sum(rate(http_errors_total{cluster="staging"}[5m])) > 5`, warning.Expr.Value)
+	require.Equal(t, model.Duration(5*time.Minute), warning.For)
+	require.Equal(t, map[string]string{"severity": "warning"}, warning.Labels)
+	require.Equal(t, map[string]string{"summary": "{{ $value }} errors per second in staging"}, warning.Annotations)
+
+	require.Equal(t, `sum(rate(http_errors_total{cluster="staging"}[5m])) > 5 * 10`, critical.Expr.Value)
+	require.Equal(t, model.Duration(15*time.Minute), critical.For)
+	require.Equal(t, map[string]string{"severity": "critical", "team": "api"}, critical.Labels)
+	require.Equal(t, map[string]string{"runbook_url": "https://runbooks.example.com/api"}, critical.Annotations)
+
+	// Without values, the templates are parsed as is and fail validation.
+	_, err = ParseFilesWithOptions(CortexBackend, []string{"testdata/templated/rules.yaml"}, ParseOptions{})
+	require.Error(t, err)
+}
@@ -0,0 +1,210 @@
+package rules
+
+import (
+	"fmt"
+)
+
+// String returns the lowercase name of the namespace state.
+func (s NamespaceState) String() string {
+	switch s {
+	case Unchanged:
+		return "unchanged"
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler, so states are serialized by
+// name in the JSON and YAML reports.
+func (s NamespaceState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ChangeReport is the machine readable representation of a set of namespace
+// changes. Its layout is part of the documented output of `rules diff` and
+// `rules sync`, fields must only ever be added to it.
+type ChangeReport struct {
+	Summary    ChangeSummary     `json:"summary" yaml:"summary"`
+	Namespaces []NamespaceReport `json:"namespaces" yaml:"namespaces"`
+	// Results is only set once the changes have been applied.
+	Results []GroupResult `json:"results,omitempty" yaml:"results,omitempty"`
+}
+
+// ChangeSummary holds the number of groups created, updated and deleted.
+type ChangeSummary struct {
+	HasChanges    bool `json:"has_changes" yaml:"has_changes"`
+	GroupsCreated int  `json:"groups_created" yaml:"groups_created"`
+	GroupsUpdated int  `json:"groups_updated" yaml:"groups_updated"`
+	GroupsDeleted int  `json:"groups_deleted" yaml:"groups_deleted"`
+	// GroupsFailed is only set once the changes have been applied.
+	GroupsFailed int `json:"groups_failed,omitempty" yaml:"groups_failed,omitempty"`
+}
+
+const (
+	// GroupResultSucceeded is the status of a rule group change that was applied.
+	GroupResultSucceeded = "succeeded"
+	// GroupResultFailed is the status of a rule group change that returned an error.
+	GroupResultFailed = "failed"
+	// GroupResultSkipped is the status of a rule group change that was not
+	// attempted because an earlier change failed.
+	GroupResultSkipped = "skipped"
+)
+
+// GroupResult is the outcome of applying the change of a single rule group.
+type GroupResult struct {
+	Namespace string         `json:"namespace" yaml:"namespace"`
+	Group     string         `json:"group" yaml:"group"`
+	Change    NamespaceState `json:"change" yaml:"change"`
+	Status    string         `json:"status" yaml:"status"`
+	Error     string         `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// WithResults returns a copy of the report including the results of applying
+// its changes.
+func (r ChangeReport) WithResults(results []GroupResult) ChangeReport {
+	r.Results = results
+	r.Summary.GroupsFailed = 0
+	for _, res := range results {
+		if res.Status == GroupResultFailed {
+			r.Summary.GroupsFailed++
+		}
+	}
+	return r
+}
+
+// SummarizeResults returns the number of rule groups created, updated and
+// deleted by the changes that succeeded, and the number of changes that failed
+// or were skipped.
+func SummarizeResults(results []GroupResult) (created, updated, deleted, failed, skipped int) {
+	for _, res := range results {
+		switch res.Status {
+		case GroupResultFailed:
+			failed++
+			continue
+		case GroupResultSkipped:
+			skipped++
+			continue
+		}
+
+		switch res.Change {
+		case Created:
+			created++
+		case Updated:
+			updated++
+		case Deleted:
+			deleted++
+		}
+	}
+	return
+}
+
+// NamespaceReport describes the changes to a single namespace.
+type NamespaceReport struct {
+	Namespace string         `json:"namespace" yaml:"namespace"`
+	State     NamespaceState `json:"state" yaml:"state"`
+	Groups    []GroupReport  `json:"groups" yaml:"groups"`
+}
+
+// GroupReport describes the changes to a single rule group. Fields is only
+// set for updated groups.
+type GroupReport struct {
+	Name   string         `json:"name" yaml:"name"`
+	State  NamespaceState `json:"state" yaml:"state"`
+	Fields []FieldDiff    `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Rules  []RuleDiff     `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// NewChangeReport builds the report of a set of namespace changes. Unchanged
+// namespaces are omitted.
+func NewChangeReport(changes []NamespaceChange) ChangeReport {
+	created, updated, deleted := SummarizeChanges(changes)
+	report := ChangeReport{
+		Summary: ChangeSummary{
+			HasChanges:    created+updated+deleted > 0,
+			GroupsCreated: created,
+			GroupsUpdated: updated,
+			GroupsDeleted: deleted,
+		},
+		Namespaces: []NamespaceReport{},
+	}
+
+	for _, change := range changes {
+		if change.State == Unchanged {
+			continue
+		}
+
+		ns := NamespaceReport{
+			Namespace: change.Namespace,
+			State:     change.State,
+			Groups:    []GroupReport{},
+		}
+
+		for _, g := range change.GroupsCreated {
+			ns.Groups = append(ns.Groups, GroupReport{
+				Name:  g.Name,
+				State: Created,
+				Rules: ruleStates(g.Rules, Created),
+			})
+		}
+
+		for _, g := range change.GroupsUpdated {
+			diff := g.Diff()
+			ns.Groups = append(ns.Groups, GroupReport{
+				Name:   g.New.Name,
+				State:  Updated,
+				Fields: diff.Fields,
+				Rules:  diff.Rules,
+			})
+		}
+
+		for _, g := range change.GroupsDeleted {
+			ns.Groups = append(ns.Groups, GroupReport{
+				Name:  g.Name,
+				State: Deleted,
+				Rules: ruleStates(g.Rules, Deleted),
+			})
+		}
+
+		report.Namespaces = append(report.Namespaces, ns)
+	}
+
+	return report
+}
+
+// TenantReport is the change report of a single tenant of a multi-tenant sync.
+type TenantReport struct {
+	Tenant       string `json:"tenant" yaml:"tenant"`
+	ChangeReport `yaml:",inline"`
+	// Error is set if the changes of the tenant couldn't be computed or applied.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// MultiTenantReport is the machine readable representation of the changes of
+// several tenants. Its summary adds up the summaries of the tenants.
+type MultiTenantReport struct {
+	Summary ChangeSummary  `json:"summary" yaml:"summary"`
+	Tenants []TenantReport `json:"tenants" yaml:"tenants"`
+}
+
+// NewMultiTenantReport builds the report of the changes of several tenants.
+func NewMultiTenantReport(tenants []TenantReport) MultiTenantReport {
+	report := MultiTenantReport{Tenants: tenants}
+	if report.Tenants == nil {
+		report.Tenants = []TenantReport{}
+	}
+
+	for _, t := range tenants {
+		report.Summary.HasChanges = report.Summary.HasChanges || t.Summary.HasChanges
+		report.Summary.GroupsCreated += t.Summary.GroupsCreated
+		report.Summary.GroupsUpdated += t.Summary.GroupsUpdated
+		report.Summary.GroupsDeleted += t.Summary.GroupsDeleted
+		report.Summary.GroupsFailed += t.Summary.GroupsFailed
+	}
+	return report
+}
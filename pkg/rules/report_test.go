@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestNewChangeReport(t *testing.T) {
+	group := func(name, expr string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{
+			RuleGroup: rulefmt.RuleGroup{
+				Name: name,
+				Rules: []rulefmt.RuleNode{
+					{Alert: yaml.Node{Value: "InstanceDown"}, Expr: yaml.Node{Value: expr}},
+				},
+			},
+		}
+	}
+
+	changes := []NamespaceChange{
+		{
+			Namespace:     "created",
+			State:         Created,
+			GroupsCreated: []rwrulefmt.RuleGroup{group("new", "up == 0")},
+		},
+		{
+			Namespace: "unchanged",
+			State:     Unchanged,
+		},
+		{
+			Namespace: "updated",
+			State:     Updated,
+			GroupsUpdated: []UpdatedRuleGroup{{
+				Original: group("changed", "up == 0"),
+				New:      group("changed", "up < 1"),
+			}},
+			GroupsDeleted: []rwrulefmt.RuleGroup{group("old", "up == 0")},
+		},
+	}
+
+	output, err := json.MarshalIndent(NewChangeReport(changes), "", "  ")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "summary": {"has_changes": true, "groups_created": 1, "groups_updated": 1, "groups_deleted": 1},
+  "namespaces": [
+    {
+      "namespace": "created",
+      "state": "created",
+      "groups": [
+        {
+          "name": "new",
+          "state": "created",
+          "rules": [
+            {"name": "InstanceDown", "kind": "alert", "state": "created", "diff": "@@ -0,0 +1,2 @@\n+alert: InstanceDown\n+expr: up == 0\n"}
+          ]
+        }
+      ]
+    },
+    {
+      "namespace": "updated",
+      "state": "updated",
+      "groups": [
+        {
+          "name": "changed",
+          "state": "updated",
+          "rules": [
+            {"name": "InstanceDown", "kind": "alert", "state": "updated", "diff": "@@ -1,2 +1,2 @@\n alert: InstanceDown\n-expr: up == 0\n+expr: up < 1\n"}
+          ]
+        },
+        {
+          "name": "old",
+          "state": "deleted",
+          "rules": [
+            {"name": "InstanceDown", "kind": "alert", "state": "deleted", "diff": "@@ -1,2 +0,0 @@\n-alert: InstanceDown\n-expr: up == 0\n"}
+          ]
+        }
+      ]
+    }
+  ]
+}`, string(output))
+}
+
+func TestNewChangeReport_NoChanges(t *testing.T) {
+	report := NewChangeReport(nil)
+	require.False(t, report.Summary.HasChanges)
+	require.NotNil(t, report.Namespaces)
+
+	output, err := yaml.Marshal(report)
+	require.NoError(t, err)
+	require.Equal(t, `summary:
+    has_changes: false
+    groups_created: 0
+    groups_updated: 0
+    groups_deleted: 0
+namespaces: []
+`, string(output))
+}
+
+func TestNewMultiTenantReport(t *testing.T) {
+	report := NewMultiTenantReport([]TenantReport{
+		{Tenant: "a", ChangeReport: ChangeReport{Summary: ChangeSummary{HasChanges: true, GroupsCreated: 1, GroupsDeleted: 2, GroupsFailed: 1}, Namespaces: []NamespaceReport{}}},
+		{Tenant: "b", ChangeReport: ChangeReport{Summary: ChangeSummary{GroupsUpdated: 0}, Namespaces: []NamespaceReport{}}, Error: "unreachable"},
+	})
+	require.Equal(t, ChangeSummary{HasChanges: true, GroupsCreated: 1, GroupsDeleted: 2, GroupsFailed: 1}, report.Summary)
+
+	output, err := json.Marshal(report.Tenants[1])
+	require.NoError(t, err)
+	require.Equal(t, `{"tenant":"b","summary":{"has_changes":false,"groups_created":0,"groups_updated":0,"groups_deleted":0},"namespaces":[],"error":"unreachable"}`, string(output))
+
+	output, err = yaml.Marshal(report.Tenants[1])
+	require.NoError(t, err)
+	require.Equal(t, `tenant: b
+summary:
+    has_changes: false
+    groups_created: 0
+    groups_updated: 0
+    groups_deleted: 0
+namespaces: []
+error: unreachable
+`, string(output))
+
+	require.NotNil(t, NewMultiTenantReport(nil).Tenants)
+}
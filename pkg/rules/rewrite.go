@@ -0,0 +1,182 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	log "github.com/sirupsen/logrus"
+)
+
+// Rewriter rewrites the PromQL expressions of rules. Operations are applied in
+// the order of the fields, every operation is disabled unless it is set.
+type Rewriter struct {
+	// RenameMetrics maps metric names to their new name. It renames the metric
+	// in every selector, and the recording rules recording it.
+	RenameMetrics map[string]string
+	// AddMatchers are added to every selector that doesn't already have a
+	// matcher for their label.
+	AddMatchers []*labels.Matcher
+	// DropLabels are removed from the grouping clauses of aggregations and the
+	// vector matching clauses of binary operations.
+	DropLabels []string
+	// RenameLabels maps label names to their new name. It renames the label in
+	// selectors, grouping and vector matching clauses and the arguments of
+	// label_replace and label_join.
+	RenameLabels map[string]string
+}
+
+// ParseRenames parses a list of old=new pairs, as used by the rename
+// operations of a Rewriter.
+func ParseRenames(values []string) (map[string]string, error) {
+	renames := make(map[string]string, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid rename %q, expected old=new", v)
+		}
+		renames[parts[0]] = parts[1]
+	}
+	return renames, nil
+}
+
+// ParseMatchers parses a list of label matchers, such as `cluster="x"`, as
+// used by the AddMatchers operation of a Rewriter.
+func ParseMatchers(values []string) ([]*labels.Matcher, error) {
+	var matchers []*labels.Matcher
+	for _, v := range values {
+		ms, err := parser.ParseMetricSelector("{" + v + "}")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid matcher %q", v)
+		}
+		matchers = append(matchers, ms...)
+	}
+	return matchers, nil
+}
+
+// Rewrite applies the operations of the rewriter to every rule of the
+// namespace. Only the expressions modified by an operation are formatted,
+// the others keep their original text. It returns the number of rules
+// evaluated and the rules that were modified.
+func (w Rewriter) Rewrite(ns RuleNamespace) (int, []ExprChange, error) {
+	return ns.inspectExprs(nil, false, func(rule *rulefmt.RuleNode) func(node parser.Node, path []parser.Node) error {
+		if name, ok := w.RenameMetrics[rule.Record.Value]; ok {
+			log.WithFields(log.Fields{
+				"namespace": ns.Namespace,
+				"rule":      rule.Record.Value,
+			}).Debugf("renaming recording rule to %s", name)
+			rule.Record.Value = name
+		}
+
+		return func(node parser.Node, path []parser.Node) error {
+			switch n := node.(type) {
+			case *parser.VectorSelector:
+				w.rewriteSelector(n)
+			case *parser.AggregateExpr:
+				n.Grouping = w.rewriteLabels(n.Grouping)
+				if n.Op == parser.COUNT_VALUES {
+					w.rewriteStringArg(n.Param)
+				}
+			case *parser.BinaryExpr:
+				if n.VectorMatching == nil {
+					return nil
+				}
+				grouped := n.VectorMatching.Card == parser.CardManyToOne || n.VectorMatching.Card == parser.CardOneToMany
+				matching := w.rewriteLabels(n.VectorMatching.MatchingLabels)
+				// An empty ignoring() is not printed, which would drop the
+				// group modifier from the expression.
+				if grouped && !n.VectorMatching.On && len(matching) == 0 {
+					matching = w.renameLabels(n.VectorMatching.MatchingLabels)
+				}
+				n.VectorMatching.MatchingLabels = matching
+				n.VectorMatching.Include = w.rewriteLabels(n.VectorMatching.Include)
+			case *parser.Call:
+				switch n.Func.Name {
+				case "label_replace":
+					// label_replace(v, dst, replacement, src, regex)
+					w.rewriteStringArg(n.Args[1])
+					w.rewriteStringArg(n.Args[3])
+				case "label_join":
+					// label_join(v, dst, separator, src...)
+					w.rewriteStringArg(n.Args[1])
+					for _, arg := range n.Args[3:] {
+						w.rewriteStringArg(arg)
+					}
+				}
+			}
+			return nil
+		}
+	})
+}
+
+func (w Rewriter) rewriteSelector(vs *parser.VectorSelector) {
+	if name, ok := w.RenameMetrics[vs.Name]; ok {
+		vs.Name = name
+	}
+
+	for i, m := range vs.LabelMatchers {
+		name, value := m.Name, m.Value
+		if m.Name == model.MetricNameLabel && m.Type == labels.MatchEqual {
+			if renamed, ok := w.RenameMetrics[m.Value]; ok {
+				value = renamed
+			}
+		}
+		if renamed, ok := w.RenameLabels[m.Name]; ok {
+			name = renamed
+		}
+		if name != m.Name || value != m.Value {
+			vs.LabelMatchers[i] = labels.MustNewMatcher(m.Type, name, value)
+		}
+	}
+
+	for _, add := range w.AddMatchers {
+		exists := false
+		for _, m := range vs.LabelMatchers {
+			if m.Name == add.Name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			vs.LabelMatchers = append(vs.LabelMatchers, add)
+		}
+	}
+}
+
+// rewriteLabels drops and renames the labels of a grouping or vector matching
+// clause.
+func (w Rewriter) rewriteLabels(lbls []string) []string {
+	var result []string
+	for _, l := range w.renameLabels(lbls) {
+		if !contains(w.DropLabels, l) {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+func (w Rewriter) renameLabels(lbls []string) []string {
+	var result []string
+	for _, l := range lbls {
+		if renamed, ok := w.RenameLabels[l]; ok {
+			l = renamed
+		}
+		result = append(result, l)
+	}
+	return result
+}
+
+// rewriteStringArg renames the label held by a string literal argument.
+func (w Rewriter) rewriteStringArg(arg parser.Expr) {
+	s, ok := unwrapExpr(arg).(*parser.StringLiteral)
+	if !ok {
+		return
+	}
+	if renamed, ok := w.RenameLabels[s.Val]; ok {
+		s.Val = renamed
+	}
+}
@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestRewriter_Rewrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		rewriter Rewriter
+		expr     string
+		expected string
+	}{
+		{
+			name:     "no operation",
+			expr:     `sum by (job) (rate(http_requests_total[5m]))`,
+			expected: `sum by (job) (rate(http_requests_total[5m]))`,
+		},
+		{
+			name:     "no operation applying",
+			rewriter: Rewriter{RenameMetrics: map[string]string{"foo_total": "bar_total"}, DropLabels: []string{"pod"}},
+			expr:     `sum(rate(http_requests_total[5m]))   by (job)`,
+			expected: `sum(rate(http_requests_total[5m]))   by (job)`,
+		},
+		{
+			name:     "rename metric",
+			rewriter: Rewriter{RenameMetrics: map[string]string{"http_requests_total": "http_server_requests_total"}},
+			expr:     `rate(http_requests_total{job="api"}[5m]) / rate({__name__="http_requests_total"}[5m])`,
+			expected: `rate(http_server_requests_total{job="api"}[5m]) / rate({__name__="http_server_requests_total"}[5m])`,
+		},
+		{
+			name:     "add matcher",
+			rewriter: Rewriter{AddMatchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "cluster", "eu")}},
+			expr:     `up{job="api"} and on (instance) up{cluster="us"} and max_over_time(up[1h:5m]) > 0`,
+			expected: `up{cluster="eu",job="api"} and on (instance) up{cluster="us"} and max_over_time(up{cluster="eu"}[1h:5m]) > 0`,
+		},
+		{
+			name:     "drop label",
+			rewriter: Rewriter{DropLabels: []string{"pod"}},
+			expr:     `sum by (job, pod) (up) / on (job, pod) group_left (team) sum without (pod) (info) * ignoring (pod, instance) group_right (pod) up * ignoring (pod) group_left up`,
+			expected: `sum by (job) (up) / on (job) group_left (team) sum without () (info) * ignoring (instance) group_right () up * ignoring (pod) group_left () up`,
+		},
+		{
+			name:     "rename label",
+			rewriter: Rewriter{RenameLabels: map[string]string{"pod": "pod_name"}},
+			expr:     `label_join(label_replace(sum by (pod) (up{pod=~"api.*"}), "pod", "$1", "pod", "(.*)"), "id", "-", "job", "pod") * on (pod) count_values("pod", info)`,
+			expected: `label_join(label_replace(sum by (pod_name) (up{pod_name=~"api.*"}), "pod_name", "$1", "pod_name", "(.*)"), "id", "-", "job", "pod_name") * on (pod_name) count_values("pod_name", info)`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := RuleNamespace{
+				Namespace: "ns",
+				Groups: []rwrulefmt.RuleGroup{{
+					RuleGroup: rulefmt.RuleGroup{
+						Name:  "group",
+						Rules: []rulefmt.RuleNode{{Alert: yaml.Node{Value: "Alert"}, Expr: yaml.Node{Value: tc.expr}}},
+					},
+				}},
+			}
+
+			count, changes, err := tc.rewriter.Rewrite(ns)
+			require.NoError(t, err)
+			require.Equal(t, 1, count)
+			require.Equal(t, tc.expected, ns.Groups[0].Rules[0].Expr.Value)
+			if tc.expr == tc.expected {
+				require.Empty(t, changes)
+			} else {
+				require.Equal(t, []ExprChange{{Namespace: "ns", Group: "group", Rule: "Alert", Before: tc.expr, After: tc.expected}}, changes)
+			}
+		})
+	}
+}
+
+func TestRewriter_RewriteRecordingRules(t *testing.T) {
+	ns := RuleNamespace{
+		Namespace: "ns",
+		Groups: []rwrulefmt.RuleGroup{{
+			RuleGroup: rulefmt.RuleGroup{
+				Name: "group",
+				Rules: []rulefmt.RuleNode{
+					{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: "sum by (job) (up)"}},
+					{Alert: yaml.Node{Value: "JobDown"}, Expr: yaml.Node{Value: "job:up:sum == 0"}},
+					{Record: yaml.Node{Value: "job:up:max"}, Expr: yaml.Node{Value: "max(up) by (job)"}},
+				},
+			},
+		}},
+	}
+
+	rewriter := Rewriter{RenameMetrics: map[string]string{"job:up:sum": "job:up:count"}}
+	count, changes, err := rewriter.Rewrite(ns)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+	require.Equal(t, []ExprChange{
+		{Namespace: "ns", Group: "group", Rule: "job:up:count", RenamedFrom: "job:up:sum", Before: "sum by (job) (up)", After: "sum by (job) (up)"},
+		{Namespace: "ns", Group: "group", Rule: "JobDown", Before: "job:up:sum == 0", After: "job:up:count == 0"},
+	}, changes)
+	require.Equal(t, "job:up:count", ns.Groups[0].Rules[0].Record.Value)
+	require.Equal(t, "max(up) by (job)", ns.Groups[0].Rules[2].Expr.Value)
+}
+
+func TestParseRenames(t *testing.T) {
+	renames, err := ParseRenames([]string{"a=b", "c=d=e"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "b", "c": "d=e"}, renames)
+
+	_, err = ParseRenames([]string{"a"})
+	require.EqualError(t, err, `invalid rename "a", expected old=new`)
+}
+
+func TestParseMatchers(t *testing.T) {
+	matchers, err := ParseMatchers([]string{`cluster="eu"`, `env=~"prod|staging"`})
+	require.NoError(t, err)
+	require.Equal(t, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, "cluster", "eu"),
+		labels.MustNewMatcher(labels.MatchRegexp, "env", "prod|staging"),
+	}, matchers)
+
+	_, err = ParseMatchers([]string{`cluster=`})
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package rules
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -19,8 +20,16 @@ type RuleNamespace struct {
 	// Namespace field only exists for setting namespace in namespace body instead of file name
 	Namespace string `yaml:"namespace,omitempty"`
 	Filepath  string `yaml:"-"`
+	// Source is set for namespaces parsed from a PrometheusRule resource.
+	Source *PrometheusRuleSource `yaml:"-"`
 
 	Groups []rwrulefmt.RuleGroup `yaml:"groups"`
+
+	// document is the index of the YAML document of the namespace in its file.
+	document int
+	// rendered is set for namespaces rendered from templates or patched by
+	// overlays, which no longer match their file.
+	rendered bool
 }
 
 // LintExpressions runs the `expr` from a rule through the PromQL or LogQL parser and
@@ -78,10 +87,6 @@ func (r RuleNamespace) LintExpressions(backend string) (int, int, error) {
 func (r RuleNamespace) CheckRecordingRules(strict bool) int {
 	var name string
 	var count int
-	reqChunks := 2
-	if strict {
-		reqChunks = 3
-	}
 	for _, group := range r.Groups {
 		for _, rule := range group.Rules {
 			// Assume if there is a rule.Record that this is a recording rule.
@@ -90,8 +95,7 @@ func (r RuleNamespace) CheckRecordingRules(strict bool) int {
 			}
 			name = rule.Record.Value
 			log.WithFields(log.Fields{"rule": name}).Debugf("linting recording rule name")
-			chunks := strings.Split(name, ":")
-			if len(chunks) < reqChunks {
+			if !validRecordingRuleName(name, strict) {
 				count++
 				log.WithFields(log.Fields{
 					"rule":      getRuleName(rule),
@@ -105,14 +109,43 @@ func (r RuleNamespace) CheckRecordingRules(strict bool) int {
 	return count
 }
 
+// ExprChange is a rule modified by AggregateBy or a Rewriter.
+type ExprChange struct {
+	Namespace string
+	Group     string
+	Rule      string
+	// RenamedFrom is the previous name of a renamed recording rule.
+	RenamedFrom string
+	Before      string
+	After       string
+}
+
 // AggregateBy modifies the aggregation rules in groups to include a given Label.
 // If the applyTo function is provided, the aggregation is applied only to rules
-// for which the applyTo function returns true.
-func (r RuleNamespace) AggregateBy(label string, applyTo func(group rwrulefmt.RuleGroup, rule rulefmt.RuleNode) bool) (int, int, error) {
+// for which the applyTo function returns true. It returns the number of rules
+// evaluated and the expressions that were modified. It fails on rules using
+// label_replace or label_join to overwrite the label.
+func (r RuleNamespace) AggregateBy(label string, applyTo func(group rwrulefmt.RuleGroup, rule rulefmt.RuleNode) bool) (int, []ExprChange, error) {
+	return r.inspectExprs(applyTo, true, func(rule *rulefmt.RuleNode) func(node parser.Node, path []parser.Node) error {
+		return exprNodeInspectorFunc(*rule, label)
+	})
+}
+
+// inspectExprs traverses the PromQL expression of every rule with the
+// inspector returned for it, and updates the rules whose expression was
+// modified. The inspector may also rename the recording rule it is returned
+// for. If the applyTo function is provided, only the rules for which it
+// returns true are traversed. If lint is set, the expressions of the rules
+// traversed are formatted too, otherwise the expressions the inspector didn't
+// modify keep their original text. It returns the number of rules evaluated
+// and the rules that were modified, or the first error returned by the
+// inspector.
+func (r RuleNamespace) inspectExprs(applyTo func(group rwrulefmt.RuleGroup, rule rulefmt.RuleNode) bool, lint bool, inspector func(rule *rulefmt.RuleNode) func(node parser.Node, path []parser.Node) error) (int, []ExprChange, error) {
 	// `count` represents the number of rules we evaluated.
-	// `mod` represents the number of rules we modified - a modification can either be a lint or adding the
-	// label in the aggregation.
-	var count, mod int
+	var count int
+	// `changes` represents the rules we modified - a modification can either be a lint or a change
+	// made by the inspector.
+	var changes []ExprChange
 
 	for i, group := range r.Groups {
 		for j, rule := range group.Rules {
@@ -130,33 +163,66 @@ func (r RuleNamespace) AggregateBy(label string, applyTo func(group rwrulefmt.Ru
 			log.WithFields(log.Fields{"rule": getRuleName(rule)}).Debugf("evaluating...")
 			exp, err := parser.ParseExpr(rule.Expr.Value)
 			if err != nil {
-				return count, mod, err
+				return count, changes, err
 			}
 
 			count++
-			// Given inspect will help us traverse every node in the AST, Let's create the
-			// function that will modify the labels.
-			f := exprNodeInspectorFunc(rule, label)
-			parser.Inspect(exp, f)
+			// Given inspect will help us traverse every node in the AST, the
+			// inspector can modify the nodes in place.
+			formatted := exp.String()
+			modified := &r.Groups[i].Rules[j]
+			if err := parser.Walk(inspectorVisitor(inspector(modified)), exp, nil); err != nil {
+				return count, changes, fmt.Errorf("rule %s in group %s: %w", getRuleName(rule), group.Name, err)
+			}
+
+			after := rule.Expr.Value
+			if lint || exp.String() != formatted {
+				after = exp.String()
+			}
 
 			// Only modify the ones that actually changed.
-			if rule.Expr.Value != exp.String() {
-				log.WithFields(log.Fields{
-					"rule":        getRuleName(rule),
-					"currentExpr": rule.Expr,
-					"afterExpr":   exp.String(),
-				}).Debugf("expression differs")
-				mod++
-				r.Groups[i].Rules[j].Expr.Value = exp.String()
+			if rule.Expr.Value == after && rule.Record.Value == modified.Record.Value {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"rule":        getRuleName(*modified),
+				"currentExpr": rule.Expr,
+				"afterExpr":   after,
+			}).Debugf("rule differs")
+
+			change := ExprChange{
+				Namespace: r.Namespace,
+				Group:     group.Name,
+				Rule:      getRuleName(*modified),
+				Before:    rule.Expr.Value,
+				After:     after,
 			}
+			if rule.Record.Value != modified.Record.Value {
+				change.RenamedFrom = rule.Record.Value
+			}
+			changes = append(changes, change)
+			modified.Expr.Value = after
 		}
 	}
 
-	return count, mod, nil
+	return count, changes, nil
+}
+
+// inspectorVisitor is a PromQL visitor calling the inspector on every node,
+// unlike parser.Inspect it returns the first error of the inspector.
+type inspectorVisitor func(node parser.Node, path []parser.Node) error
+
+func (f inspectorVisitor) Visit(node parser.Node, path []parser.Node) (parser.Visitor, error) {
+	if err := f(node, path); err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
 // exprNodeInspectorFunc returns a PromQL inspector.
 // It modifies most PromQL expressions to include a given label.
+// Subqueries and the arguments of functions are traversed like any other node.
 func exprNodeInspectorFunc(rule rulefmt.RuleNode, label string) func(node parser.Node, path []parser.Node) error {
 	return func(node parser.Node, path []parser.Node) error {
 		var err error
@@ -165,6 +231,8 @@ func exprNodeInspectorFunc(rule rulefmt.RuleNode, label string) func(node parser
 			err = prepareAggregationExpr(n, label, getRuleName(rule))
 		case *parser.BinaryExpr:
 			err = prepareBinaryExpr(n, label, getRuleName(rule))
+		case *parser.Call:
+			err = prepareCall(n, label, getRuleName(rule))
 		default:
 			return err
 		}
@@ -174,18 +242,24 @@ func exprNodeInspectorFunc(rule rulefmt.RuleNode, label string) func(node parser
 }
 
 func prepareAggregationExpr(e *parser.AggregateExpr, label string, ruleName string) error {
-	// If the aggregation is about dropping labels (e.g. without), we don't want to modify
-	// this expression. Omission as long as it is not the cluster label will include it.
-	// TODO: We probably want to check whenever the label we're trying to include is included in the omission.
+	// If the aggregation is about dropping labels (e.g. without), the label is
+	// kept as long as it is not one of the dropped labels.
 	if e.Without {
+		if !contains(e.Grouping, label) {
+			return nil
+		}
+
+		log.WithFields(
+			log.Fields{"rule": ruleName, "lbls": strings.Join(e.Grouping, ", ")},
+		).Debugf("aggregation without '%s' label, removing it.", label)
+
+		e.Grouping = removeLabel(e.Grouping, label)
 		return nil
 	}
 
-	for _, lbl := range e.Grouping {
-		// It already has the label we want to aggregate by.
-		if lbl == label {
-			return nil
-		}
+	// It already has the label we want to aggregate by.
+	if contains(e.Grouping, label) {
+		return nil
 	}
 
 	log.WithFields(
@@ -202,14 +276,45 @@ func prepareBinaryExpr(e *parser.BinaryExpr, label string, rule string) error {
 	}
 
 	if !e.VectorMatching.On {
-		return nil
-	}
+		grouped := e.VectorMatching.Card == parser.CardManyToOne || e.VectorMatching.Card == parser.CardOneToMany
+
+		// The label is matched unless it is ignored.
+		if contains(e.VectorMatching.MatchingLabels, label) {
+			// An empty ignoring() is not printed, which would drop the group
+			// modifier from the expression.
+			if grouped && len(e.VectorMatching.MatchingLabels) == 1 {
+				return fmt.Errorf("binary expression only ignores the '%s' label with a group modifier", label)
+			}
+
+			log.WithFields(
+				log.Fields{"rule": rule, "lbls": strings.Join(e.VectorMatching.MatchingLabels, ", ")},
+			).Debugf("binary expression ignoring '%s' label, removing it.", label)
 
-	for _, lbl := range e.VectorMatching.MatchingLabels {
-		// It already has the label we want to add in the expression.
-		if lbl == label {
+			e.VectorMatching.MatchingLabels = removeLabel(e.VectorMatching.MatchingLabels, label)
+		}
+
+		// group_left and group_right copy the label from the "one" side.
+		if !grouped {
 			return nil
 		}
+		if contains(e.VectorMatching.Include, label) {
+			return nil
+		}
+
+		log.WithFields(
+			log.Fields{"rule": rule, "lbls": strings.Join(e.VectorMatching.Include, ", ")},
+		).Debugf("group modifier without '%s' label, adding.", label)
+
+		e.VectorMatching.Include = append(e.VectorMatching.Include, label)
+		return nil
+	}
+
+	// A label can't be both in on() and in the group modifier.
+	e.VectorMatching.Include = removeLabel(e.VectorMatching.Include, label)
+
+	// It already has the label we want to add in the expression.
+	if contains(e.VectorMatching.MatchingLabels, label) {
+		return nil
 	}
 
 	log.WithFields(
@@ -220,6 +325,34 @@ func prepareBinaryExpr(e *parser.BinaryExpr, label string, rule string) error {
 	return nil
 }
 
+// prepareCall fails on label_replace and label_join calls that overwrite the
+// label, the rule would no longer be aggregated by it.
+func prepareCall(e *parser.Call, label string, rule string) error {
+	if e.Func.Name != "label_replace" && e.Func.Name != "label_join" {
+		return nil
+	}
+
+	dst, ok := e.Args[1].(*parser.StringLiteral)
+	if !ok || dst.Val != label {
+		return nil
+	}
+
+	log.WithFields(
+		log.Fields{"rule": rule, "function": e.Func.Name},
+	).Debugf("function overwrites the '%s' label.", label)
+	return fmt.Errorf("%s overwrites the '%s' label", e.Func.Name, label)
+}
+
+func removeLabel(lbls []string, label string) []string {
+	var result []string
+	for _, lbl := range lbls {
+		if lbl != label {
+			result = append(result, lbl)
+		}
+	}
+	return result
+}
+
 // Validate each rule in the rule namespace is valid
 func (r RuleNamespace) Validate() []error {
 	set := map[string]struct{}{}
@@ -248,6 +381,22 @@ func (r RuleNamespace) Validate() []error {
 // ValidateRuleGroup validates a rulegroup
 func ValidateRuleGroup(g rwrulefmt.RuleGroup) []error {
 	var errs []error
+
+	if g.Limit < 0 {
+		errs = append(errs, fmt.Errorf("group %q: limit must not be negative", g.Name))
+	}
+
+	tenants := map[string]struct{}{}
+	for _, tenant := range g.SourceTenants {
+		if err := validateTenantID(tenant); err != nil {
+			errs = append(errs, fmt.Errorf("group %q: invalid source tenant %q: %w", g.Name, tenant, err))
+		}
+		if _, ok := tenants[tenant]; ok {
+			errs = append(errs, fmt.Errorf("group %q: source tenant %q is repeated", g.Name, tenant))
+		}
+		tenants[tenant] = struct{}{}
+	}
+
 	for i, r := range g.Rules {
 		for _, err := range r.Validate() {
 			var ruleName string
@@ -268,6 +417,32 @@ func ValidateRuleGroup(g rwrulefmt.RuleGroup) []error {
 	return errs
 }
 
+// maxTenantIDLength is the maximum length of a Cortex tenant ID.
+const maxTenantIDLength = 150
+
+// validateTenantID checks a tenant ID is valid for Cortex, which only allows
+// alphanumeric characters and !-_.*'() in them.
+func validateTenantID(id string) error {
+	switch {
+	case id == "":
+		return errors.New("tenant ID is empty")
+	case id == "." || id == "..":
+		return errors.New(`tenant ID is "." or ".."`)
+	case len(id) > maxTenantIDLength:
+		return fmt.Errorf("tenant ID is longer than %d characters", maxTenantIDLength)
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!-_.*'()", r):
+		default:
+			return fmt.Errorf("tenant ID contains unsupported character %q", r)
+		}
+	}
+	return nil
+}
+
 func getRuleName(r rulefmt.RuleNode) string {
 	if r.Record.Value != "" {
 		return r.Record.Value
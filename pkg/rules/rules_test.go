@@ -141,6 +141,91 @@ func TestAggregateBy(t *testing.T) {
 			expectedExpr: []string{`count by (cluster, node) (sum by (node, cpu, cluster) (node_cpu_seconds_total{job="default/node-exporter"} * on (namespace, instance, cluster) group_left (node) node_namespace_pod:kube_pod_info:))`},
 			count:        1, modified: 1, expect: nil,
 		},
+		{
+			name: "with the label dropped by 'without'",
+			rn: RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{
+					{
+						RuleGroup: rulefmt.RuleGroup{
+							Name: "Without",
+							Rules: []rulefmt.RuleNode{
+								{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: `sum without (cluster, instance) (up)`}},
+								{Record: yaml.Node{Value: "job:up:max"}, Expr: yaml.Node{Value: `max without (cluster) (up)`}},
+							},
+						},
+					},
+				},
+			},
+			expectedExpr: []string{`sum without (instance) (up)`, `max without () (up)`},
+			count:        2, modified: 2, expect: nil,
+		},
+		{
+			name: "with group modifiers",
+			rn: RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{
+					{
+						RuleGroup: rulefmt.RuleGroup{
+							Name: "GroupModifiers",
+							Rules: []rulefmt.RuleNode{
+								{Record: yaml.Node{Value: "a"}, Expr: yaml.Node{Value: `up * ignoring (cluster, instance) group_left (team) info`}},
+								{Record: yaml.Node{Value: "b"}, Expr: yaml.Node{Value: `info * ignoring (instance) group_right up`}},
+								{Record: yaml.Node{Value: "c"}, Expr: yaml.Node{Value: `up * on (job) group_left (cluster, team) info`}},
+							},
+						},
+					},
+				},
+			},
+			expectedExpr: []string{
+				`up * ignoring (instance) group_left (team, cluster) info`,
+				`info * ignoring (instance) group_right (cluster) up`,
+				`up * on (job, cluster) group_left (team) info`,
+			},
+			count: 3, modified: 3, expect: nil,
+		},
+		{
+			name: "with label functions",
+			rn: RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{
+					{
+						RuleGroup: rulefmt.RuleGroup{
+							Name: "Functions",
+							Rules: []rulefmt.RuleNode{
+								{Record: yaml.Node{Value: "a"}, Expr: yaml.Node{Value: `label_join(sum by (job) (up), "name", "-", "job")`}},
+								{Record: yaml.Node{Value: "b"}, Expr: yaml.Node{Value: `label_replace(up, "team", "$1", "job", "(.*)")`}},
+							},
+						},
+					},
+				},
+			},
+			expectedExpr: []string{
+				`label_join(sum by (job, cluster) (up), "name", "-", "job")`,
+				`label_replace(up, "team", "$1", "job", "(.*)")`,
+			},
+			count: 2, modified: 1, expect: nil,
+		},
+		{
+			name: "with subqueries",
+			rn: RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{
+					{
+						RuleGroup: rulefmt.RuleGroup{
+							Name: "Subqueries",
+							Rules: []rulefmt.RuleNode{
+								{Record: yaml.Node{Value: "a"}, Expr: yaml.Node{Value: `max_over_time(sum by (job) (rate(requests_total[5m]))[1h:5m])`}},
+								{Record: yaml.Node{Value: "b"}, Expr: yaml.Node{Value: `sum without (instance) (avg_over_time((up * on (instance) group_left (team) info)[30m:1m]))`}},
+								{Record: yaml.Node{Value: "c"}, Expr: yaml.Node{Value: `max_over_time(max by (job) (max_over_time(up[5m:1m]))[1h:5m])`}},
+							},
+						},
+					},
+				},
+			},
+			expectedExpr: []string{
+				`max_over_time(sum by (job, cluster) (rate(requests_total[5m]))[1h:5m])`,
+				`sum without (instance) (avg_over_time((up * on (instance, cluster) group_left (team) info)[30m:1m]))`,
+				`max_over_time(max by (job, cluster) (max_over_time(up[5m:1m]))[1h:5m])`,
+			},
+			count: 3, modified: 3, expect: nil,
+		},
 		{
 			name: "with a query skipped",
 			rn: RuleNamespace{
@@ -186,11 +271,11 @@ func TestAggregateBy(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			c, m, err := tc.rn.AggregateBy("cluster", tc.applyTo)
+			c, changes, err := tc.rn.AggregateBy("cluster", tc.applyTo)
 
 			require.Equal(t, tc.expect, err)
 			assert.Equal(t, tc.count, c)
-			assert.Equal(t, tc.modified, m)
+			assert.Equal(t, tc.modified, len(changes))
 
 			// Only verify the PromQL expression if it has been modified
 			expectedIdx := 0
@@ -204,6 +289,29 @@ func TestAggregateBy(t *testing.T) {
 	}
 }
 
+func TestAggregateBy_Unsupported(t *testing.T) {
+	for expr, expected := range map[string]string{
+		`label_replace(sum by (job) (up), "cluster", "$1", "job", "(.*)")`:        "label_replace overwrites the 'cluster' label",
+		`max_over_time(label_join(up, "cluster", "-", "job", "instance")[1h:5m])`: "label_join overwrites the 'cluster' label",
+		`sum by (job) (up * ignoring (cluster) group_left info)`:                  "binary expression only ignores the 'cluster' label with a group modifier",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			rn := RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{{
+					RuleGroup: rulefmt.RuleGroup{
+						Name:  "Unsupported",
+						Rules: []rulefmt.RuleNode{{Record: yaml.Node{Value: "a"}, Expr: yaml.Node{Value: expr}}},
+					},
+				}},
+			}
+
+			_, _, err := rn.AggregateBy("cluster", nil)
+			require.EqualError(t, err, "rule a in group Unsupported: "+expected)
+			require.Equal(t, expr, rn.Groups[0].Rules[0].Expr.Value)
+		})
+	}
+}
+
 func TestLintExpressions(t *testing.T) {
 	tt := []struct {
 		name            string
@@ -379,3 +487,22 @@ func TestCheckRecordingRules(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRuleGroup(t *testing.T) {
+	group := func(limit int, sourceTenants ...string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{
+			RuleGroup:     rulefmt.RuleGroup{Name: "group", Limit: limit},
+			SourceTenants: sourceTenants,
+		}
+	}
+
+	require.Empty(t, ValidateRuleGroup(group(10, "tenant-a", "team_b.prod", "(c)")))
+
+	errs := ValidateRuleGroup(group(-1, "tenant-a", "", "..", "a|b", "tenant-a"))
+	require.Len(t, errs, 5)
+	require.EqualError(t, errs[0], `group "group": limit must not be negative`)
+	require.EqualError(t, errs[1], `group "group": invalid source tenant "": tenant ID is empty`)
+	require.EqualError(t, errs[2], `group "group": invalid source tenant "..": tenant ID is "." or ".."`)
+	require.EqualError(t, errs[3], `group "group": invalid source tenant "a|b": tenant ID contains unsupported character '|'`)
+	require.EqualError(t, errs[4], `group "group": source tenant "tenant-a" is repeated`)
+}
@@ -9,6 +9,9 @@ type RuleGroup struct {
 	rulefmt.RuleGroup `yaml:",inline"`
 	// RWConfigs is used by the remote write forwarding ruler
 	RWConfigs []RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	// SourceTenants are the tenants queried by the rules of a federated rule
+	// group, instead of the tenant owning the group.
+	SourceTenants []string `yaml:"source_tenants,omitempty"`
 }
 
 // RemoteWriteConfig is used to specify a remote write endpoint
@@ -0,0 +1,306 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/template"
+)
+
+// Names of the template checks, as reported in findings.
+const (
+	TemplateCheck      = "template"
+	TemplateLabelCheck = "template-label"
+)
+
+// templateDefs are the variables the Prometheus rule manager defines before
+// expanding the labels and annotations of an alert.
+var templateDefs = []string{
+	"{{$labels := .Labels}}",
+	"{{$externalLabels := .ExternalLabels}}",
+	"{{$externalURL := .ExternalURL}}",
+	"{{$value := .Value}}",
+}
+
+// syntheticLabelValue is the value of the labels of the synthetic alerts the
+// templates are executed against. It is numeric so it can be passed to the
+// humanize functions.
+const syntheticLabelValue = "0"
+
+// CheckTemplates executes the label and annotation templates of every alerting
+// rule of the namespace against a synthetic alert, with the Prometheus
+// template package. The labels of the synthetic alert are the ones the rule
+// expression is known to return, if any. Findings are returned for templates
+// that fail to parse or execute, and for templates that reference a label the
+// expression doesn't return. Like in the Prometheus rule manager, the labels
+// of the rule itself are not available to the templates.
+func CheckTemplates(ns RuleNamespace) []Finding {
+	var findings []Finding
+
+	for _, g := range ns.Groups {
+		for _, r := range g.Rules {
+			if r.Alert.Value == "" {
+				continue
+			}
+
+			for _, w := range checkRuleTemplates(r) {
+				w.File = ns.Filepath
+				w.Namespace = ns.Namespace
+				w.Group = g.Name
+				w.Rule = r.Alert.Value
+				findings = append(findings, w)
+			}
+		}
+	}
+
+	return findings
+}
+
+// checkRuleTemplates returns the findings of the templates of an alerting
+// rule, in the order of the sorted label and annotation names. Only the Check
+// and Message of the findings are set.
+func checkRuleTemplates(r rulefmt.RuleNode) []Finding {
+	var (
+		findings []Finding
+		returned []string
+		known    bool
+	)
+	if expr, err := parser.ParseExpr(r.Expr.Value); err == nil {
+		returned, known = outputLabels(expr)
+	}
+
+	check := func(kind string, templates map[string]string) {
+		for _, name := range sortedStringKeys(templates) {
+			text := strings.Join(append(templateDefs, templates[name]), "")
+
+			// The labels the expression doesn't return are still set on the
+			// synthetic alert, so they are only reported once.
+			alertLabels := map[string]string{}
+			for _, l := range returned {
+				alertLabels[l] = syntheticLabelValue
+			}
+			for _, l := range referencedLabels(text) {
+				if _, ok := alertLabels[l]; !ok && known {
+					findings = append(findings, Finding{Check: TemplateLabelCheck, Message: fmt.Sprintf("%s %q references label %q which is not returned by the expression", kind, name, l)})
+				}
+				alertLabels[l] = syntheticLabelValue
+			}
+
+			expander := template.NewTemplateExpander(
+				context.Background(),
+				text,
+				"__alert_"+r.Alert.Value,
+				template.AlertTemplateData(alertLabels, map[string]string{}, "", 0),
+				model.Now(),
+				syntheticQuery,
+				nil,
+				nil,
+			)
+			if _, err := expander.Expand(); err != nil {
+				findings = append(findings, Finding{Check: TemplateCheck, Message: fmt.Sprintf("%s %q: %s", kind, name, err)})
+			}
+		}
+	}
+	check("label", r.Labels)
+	check("annotation", r.Annotations)
+
+	return findings
+}
+
+// syntheticQuery validates the queries run by templates and returns a single
+// sample without labels, so functions like first() can be executed.
+func syntheticQuery(_ context.Context, q string, ts time.Time) (promql.Vector, error) {
+	if _, err := parser.ParseExpr(q); err != nil {
+		return nil, err
+	}
+	return promql.Vector{{T: ts.UnixMilli(), F: 0, Metric: labels.EmptyLabels()}}, nil
+}
+
+// referencedLabels returns the sorted names of the labels referenced by a
+// template as $labels.name, .Labels.name or index $labels "name". It returns
+// nil if the template can't be parsed.
+func referencedLabels(text string) []string {
+	tree := parse.New("")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(text, "", "", map[string]*parse.Tree{}); err != nil {
+		return nil
+	}
+
+	refs := map[string]struct{}{}
+
+	var walk func(node parse.Node, dotIsData bool)
+	walk = func(node parse.Node, dotIsData bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c, dotIsData)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, dotIsData)
+		case *parse.IfNode:
+			walk(n.Pipe, dotIsData)
+			walk(n.List, dotIsData)
+			walk(n.ElseList, dotIsData)
+		case *parse.RangeNode:
+			walk(n.Pipe, dotIsData)
+			walk(n.List, false)
+			walk(n.ElseList, dotIsData)
+		case *parse.WithNode:
+			walk(n.Pipe, dotIsData)
+			walk(n.List, false)
+			walk(n.ElseList, dotIsData)
+		case *parse.TemplateNode:
+			walk(n.Pipe, dotIsData)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c, dotIsData)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) == 3 {
+				fn, isIdent := n.Args[0].(*parse.IdentifierNode)
+				v, isVar := n.Args[1].(*parse.VariableNode)
+				s, isString := n.Args[2].(*parse.StringNode)
+				if isIdent && isVar && isString && fn.Ident == "index" && len(v.Ident) == 1 && v.Ident[0] == "$labels" {
+					refs[s.Text] = struct{}{}
+				}
+			}
+			for _, c := range n.Args {
+				walk(c, dotIsData)
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$labels" {
+				refs[n.Ident[1]] = struct{}{}
+			}
+		case *parse.FieldNode:
+			if dotIsData && len(n.Ident) > 1 && n.Ident[0] == "Labels" {
+				refs[n.Ident[1]] = struct{}{}
+			}
+		}
+	}
+	walk(tree.Root, true)
+
+	result := make([]string, 0, len(refs))
+	for l := range refs {
+		result = append(result, l)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// outputLabels returns the sorted names of the labels of the series returned
+// by an expression, known is false if they can't be known from the expression
+// alone.
+func outputLabels(expr parser.Expr) (result []string, known bool) {
+	switch e := unwrapExpr(expr).(type) {
+	case *parser.AggregateExpr:
+		return groupingLabels(e)
+
+	case *parser.BinaryExpr:
+		switch {
+		case e.LHS.Type() != parser.ValueTypeVector:
+			return outputLabels(e.RHS)
+		case e.RHS.Type() != parser.ValueTypeVector:
+			return outputLabels(e.LHS)
+		case e.Op == parser.LOR || e.VectorMatching == nil:
+			return nil, false
+		}
+
+		switch e.VectorMatching.Card {
+		case parser.CardManyToMany:
+			return outputLabels(e.LHS)
+		case parser.CardOneToOne:
+			if e.VectorMatching.On {
+				return mergeLabels(e.VectorMatching.MatchingLabels, nil), true
+			}
+			if result, known = outputLabels(e.LHS); known {
+				return removeLabels(result, e.VectorMatching.MatchingLabels), true
+			}
+		case parser.CardManyToOne:
+			if result, known = outputLabels(e.LHS); known {
+				return mergeLabels(result, e.VectorMatching.Include), true
+			}
+		case parser.CardOneToMany:
+			if result, known = outputLabels(e.RHS); known {
+				return mergeLabels(result, e.VectorMatching.Include), true
+			}
+		}
+		return nil, false
+
+	case *parser.Call:
+		switch e.Func.Name {
+		case "vector":
+			return []string{}, true
+		case "absent", "absent_over_time":
+			var vs *parser.VectorSelector
+			switch arg := unwrapExpr(e.Args[0]).(type) {
+			case *parser.VectorSelector:
+				vs = arg
+			case *parser.MatrixSelector:
+				vs, _ = arg.VectorSelector.(*parser.VectorSelector)
+			}
+			if vs == nil {
+				return nil, false
+			}
+
+			result = []string{}
+			for _, m := range vs.LabelMatchers {
+				if m.Type == labels.MatchEqual && m.Name != model.MetricNameLabel {
+					result = append(result, m.Name)
+				}
+			}
+			return mergeLabels(result, nil), true
+		}
+	}
+
+	return nil, false
+}
+
+// mergeLabels returns the sorted union of two lists of label names.
+func mergeLabels(a, b []string) []string {
+	set := map[string]struct{}{}
+	for _, l := range append(append([]string{}, a...), b...) {
+		set[l] = struct{}{}
+	}
+
+	result := make([]string, 0, len(set))
+	for l := range set {
+		result = append(result, l)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// removeLabels returns the label names of a that are not in b.
+func removeLabels(a, b []string) []string {
+	result := []string{}
+	for _, l := range a {
+		if !contains(b, l) {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,158 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v3"
+
+	"github.com/grafana/cortex-tools/pkg/rules/rwrulefmt"
+)
+
+func TestCheckTemplates(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		labels      map[string]string
+		annotations map[string]string
+		expected    []Finding
+		// errors are substrings of the messages of the template check findings.
+		errors []string
+	}{
+		{
+			name:        "valid templates",
+			expr:        `sum by (job) (up) == 0`,
+			labels:      map[string]string{"severity": "critical", "job": "{{ $labels.job }}"},
+			annotations: map[string]string{"summary": `{{ .Labels.job }} is down, {{ $value | humanize }} up, {{ index $labels "job" }}`},
+		},
+		{
+			name:        "labels of the series are unknown",
+			expr:        `up == 0`,
+			annotations: map[string]string{"summary": "{{ $labels.instance }} is down since {{ $labels.since | humanizeDuration }}"},
+		},
+		{
+			name:   "label not returned by the expression",
+			expr:   `sum by (job) (up) == 0`,
+			labels: map[string]string{"instance": "{{ $labels.instance }}"},
+			annotations: map[string]string{
+				"description": `{{ index $labels "namespace" }}`,
+				"summary":     "{{ .Labels.pod }} {{ $labels.job }}",
+			},
+			expected: []Finding{
+				{Check: TemplateLabelCheck, Message: `label "instance" references label "instance" which is not returned by the expression`},
+				{Check: TemplateLabelCheck, Message: `annotation "description" references label "namespace" which is not returned by the expression`},
+				{Check: TemplateLabelCheck, Message: `annotation "summary" references label "pod" which is not returned by the expression`},
+			},
+		},
+		{
+			name:        "labels within range are not the labels of the alert",
+			expr:        `sum by (job) (up) == 0`,
+			annotations: map[string]string{"summary": `{{ range query "up" }}{{ .Labels.instance }}{{ end }}`},
+		},
+		{
+			name:        "label kept by on()",
+			expr:        `sum by (job, env) (up) / on (job) sum by (job) (up) < 0.5`,
+			annotations: map[string]string{"summary": "{{ $labels.job }} {{ $labels.env }}"},
+			expected: []Finding{
+				{Check: TemplateLabelCheck, Message: `annotation "summary" references label "env" which is not returned by the expression`},
+			},
+		},
+		{
+			name:        "label matched by absent",
+			expr:        `absent(up{job="api"})`,
+			annotations: map[string]string{"summary": "{{ $labels.job }}"},
+		},
+		{
+			name:        "execution error",
+			expr:        `up == 0`,
+			annotations: map[string]string{"summary": "{{ .Instance }}"},
+			errors:      []string{`annotation "summary": error executing template __alert_Alert`},
+		},
+		{
+			name:        "invalid query",
+			expr:        `up == 0`,
+			annotations: map[string]string{"summary": `{{ query "up{" | first | value }}`},
+			errors:      []string{`annotation "summary": error executing template __alert_Alert`},
+		},
+		{
+			name:        "parse error",
+			expr:        `up == 0`,
+			annotations: map[string]string{"summary": "{{ $value | humanize }"},
+			errors:      []string{`annotation "summary": error parsing template __alert_Alert`},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := RuleNamespace{
+				Namespace: "ns",
+				Filepath:  "rules.yaml",
+				Groups: []rwrulefmt.RuleGroup{{
+					RuleGroup: rulefmt.RuleGroup{
+						Name: "group",
+						Rules: []rulefmt.RuleNode{
+							{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: "sum by (job) (up)"}, Labels: map[string]string{"instance": "{{ $labels.instance }}"}},
+							{Alert: yaml.Node{Value: "Alert"}, Expr: yaml.Node{Value: tc.expr}, Labels: tc.labels, Annotations: tc.annotations},
+						},
+					},
+				}},
+			}
+
+			findings := CheckTemplates(ns)
+			for _, f := range findings {
+				require.Equal(t, "rules.yaml", f.File)
+				require.Equal(t, "ns", f.Namespace)
+				require.Equal(t, "group", f.Group)
+				require.Equal(t, "Alert", f.Rule)
+			}
+
+			if len(tc.errors) > 0 {
+				require.Len(t, findings, len(tc.errors))
+				for i, f := range findings {
+					require.Equal(t, TemplateCheck, f.Check)
+					require.Contains(t, f.Message, tc.errors[i])
+				}
+				return
+			}
+
+			var expected []Finding
+			for _, f := range tc.expected {
+				f.File, f.Namespace, f.Group, f.Rule = "rules.yaml", "ns", "group", "Alert"
+				expected = append(expected, f)
+			}
+			require.Equal(t, expected, findings)
+		})
+	}
+}
+
+func TestOutputLabels(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected []string
+		known    bool
+	}{
+		{expr: `up`},
+		{expr: `sum(up)`, known: true},
+		{expr: `sum by (job, env) (up) > 0`, expected: []string{"env", "job"}, known: true},
+		{expr: `sum without (instance) (up)`},
+		{expr: `sum by (job, env) (up) / ignoring (env) sum by (job) (up)`, expected: []string{"job"}, known: true},
+		{expr: `sum by (job) (up) * on (job) group_left (team) sum by (job, team) (info)`, expected: []string{"job", "team"}, known: true},
+		{expr: `sum by (job) (up) and on (job) sum by (job, env) (up)`, expected: []string{"job"}, known: true},
+		{expr: `sum by (job) (up) or sum by (env) (up)`},
+		{expr: `vector(1)`, expected: []string{}, known: true},
+		{expr: `absent_over_time(up{job="api", instance=~"a.*"}[5m])`, expected: []string{"job"}, known: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tc.expr)
+			require.NoError(t, err)
+
+			result, known := outputLabels(expr)
+			require.Equal(t, tc.known, known)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
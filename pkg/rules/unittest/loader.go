@@ -0,0 +1,57 @@
+package unittest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	cortexrules "github.com/grafana/cortex-tools/pkg/rules"
+)
+
+// groupLoader implements the Prometheus rules.GroupLoader interface on top of
+// cortextool rule files. Rule groups are loaded by namespace instead of by
+// file, so the same group name can be used in different namespaces.
+type groupLoader struct {
+	nss map[string]cortexrules.RuleNamespace
+}
+
+func newGroupLoader(files []string) (*groupLoader, error) {
+	nss, err := cortexrules.ParseFiles(cortexrules.CortexBackend, files)
+	if err != nil {
+		return nil, err
+	}
+	return &groupLoader{nss: nss}, nil
+}
+
+// namespaces returns the sorted names of the loaded namespaces.
+func (l *groupLoader) namespaces() []string {
+	names := make([]string, 0, len(l.nss))
+	for name := range l.nss {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the rule groups of a namespace. The remote_write configuration
+// of the groups is ignored, the results of recording rules are written to the
+// test storage.
+func (l *groupLoader) Load(namespace string) (*rulefmt.RuleGroups, []error) {
+	ns, ok := l.nss[namespace]
+	if !ok {
+		return nil, []error{fmt.Errorf("namespace %s not found", namespace)}
+	}
+
+	rgs := &rulefmt.RuleGroups{}
+	for _, g := range ns.Groups {
+		rgs.Groups = append(rgs.Groups, g.RuleGroup)
+	}
+	return rgs, nil
+}
+
+// Parse parses a PromQL expression.
+func (l *groupLoader) Parse(query string) (parser.Expr, error) {
+	return parser.ParseExpr(query)
+}
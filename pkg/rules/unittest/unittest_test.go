@@ -0,0 +1,51 @@
+package unittest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("passing tests", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.True(t, Run(out, "testdata/success.yaml"), out.String())
+		assert.Contains(t, out.String(), "SUCCESS")
+	})
+
+	t.Run("tests without interval use the evaluation interval", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.True(t, Run(out, "testdata/default_interval.yaml"), out.String())
+		assert.Contains(t, out.String(), "SUCCESS")
+	})
+
+	t.Run("failing tests are reported per test case", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.False(t, Run(out, "testdata/failure.yaml"))
+		assert.Contains(t, out.String(), "FAILED")
+		assert.Contains(t, out.String(), "name: wrong expectations,\n    alertname: InstanceDown, time: 2m")
+		assert.Contains(t, out.String(), "name: wrong expectations,\n    expr: \"job:up:sum\", time: 4m")
+	})
+
+	t.Run("missing test file", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		require.False(t, Run(out, "testdata/missing.yaml"))
+	})
+}
+
+func TestGroupLoader(t *testing.T) {
+	loader, err := newGroupLoader([]string{"testdata/rules.yaml", "testdata/rules_other.yaml"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"example", "other"}, loader.namespaces())
+
+	rgs, errs := loader.Load("example")
+	require.Empty(t, errs)
+	require.Len(t, rgs.Groups, 1)
+	assert.Equal(t, "example", rgs.Groups[0].Name)
+	assert.Len(t, rgs.Groups[0].Rules, 2)
+
+	_, errs = loader.Load("unknown")
+	require.Len(t, errs, 1)
+}